@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const catalogCacheFileName = "catalog.json"
+
+// Catalog is the result of a catalog fetch together with information about where it came from.
+type Catalog struct {
+	Maps        []Map
+	LastUpdated time.Time // As reported by the API
+	FetchedAt   time.Time // When the data was last confirmed with the server
+	FromCache   bool      // The maps were read from the on-disk cache
+	Offline     bool      // The server could not be reached (or offline mode is on) and the cache was used
+}
+
+// Age returns how long ago the catalog was last confirmed with the server.
+func (c *Catalog) Age() time.Duration {
+	if c.FetchedAt.IsZero() {
+		return 0
+	}
+	return time.Since(c.FetchedAt)
+}
+
+// AgeText describes the catalog age in words, for example "3 hours ago".
+func (c *Catalog) AgeText() string {
+	age := c.Age()
+	switch {
+	case age < time.Minute:
+		return "just now"
+	case age < time.Hour:
+		return pluralAgo(int(age/time.Minute), "minute")
+	case age < 24*time.Hour:
+		return pluralAgo(int(age/time.Hour), "hour")
+	default:
+		return pluralAgo(int(age/(24*time.Hour)), "day")
+	}
+}
+
+func pluralAgo(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s ago", unit)
+	}
+	return fmt.Sprintf("%d %ss ago", n, unit)
+}
+
+// catalogCacheEntry is the on-disk format of the cached catalog.
+type catalogCacheEntry struct {
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"last_modified,omitempty"`
+	FetchedAt    time.Time   `json:"fetched_at"`
+	Response     APIResponse `json:"response"`
+}
+
+// DefaultCacheDir returns the directory SMM uses for cached data.
+func DefaultCacheDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "skaterxl-map-manager"), nil
+}
+
+func (c *Client) catalogCachePath() string {
+	if c.CacheDir == "" {
+		return ""
+	}
+	return filepath.Join(c.CacheDir, catalogCacheFileName)
+}
+
+// loadCatalogCache reads the cached catalog. It returns nil if caching is disabled or nothing is cached.
+func (c *Client) loadCatalogCache() *catalogCacheEntry {
+	cachePath := c.catalogCachePath()
+	if cachePath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			Logger.Printf("Error reading catalog cache '%s': %v", cachePath, err)
+		}
+		return nil
+	}
+
+	var entry catalogCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		Logger.Printf("Ignoring corrupt catalog cache '%s': %v", cachePath, err)
+		return nil
+	}
+	return &entry
+}
+
+// saveCatalogCache writes entry atomically. Failures are logged because the cache is only an optimization.
+func (c *Client) saveCatalogCache(entry *catalogCacheEntry) {
+	cachePath := c.catalogCachePath()
+	if cachePath == "" {
+		return
+	}
+	if err := os.MkdirAll(c.CacheDir, 0700); err != nil {
+		Logger.Printf("Error creating cache directory '%s': %v", c.CacheDir, err)
+		return
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		Logger.Printf("Error marshaling catalog cache: %v", err)
+		return
+	}
+
+	tmpPath := cachePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		Logger.Printf("Error writing catalog cache: %v", err)
+		return
+	}
+	if err := os.Rename(tmpPath, cachePath); err != nil {
+		os.Remove(tmpPath)
+		Logger.Printf("Error replacing catalog cache: %v", err)
+	}
+}
+
+func (entry *catalogCacheEntry) catalog(offline bool) *Catalog {
+	return &Catalog{
+		Maps:        entry.Response.Items,
+		LastUpdated: entry.Response.LastUpdated,
+		FetchedAt:   entry.FetchedAt,
+		FromCache:   true,
+		Offline:     offline,
+	}
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBaseURL is the skatebit API root for Skater XL.
+const DefaultBaseURL = "https://api.skatebit.app/api/v1/skaterxl"
+
+// APIEndpoint is the catalog endpoint of the default API.
+const APIEndpoint = DefaultBaseURL + "/maps"
+
+// DefaultUserAgent is sent with every request unless the client overrides it.
+const DefaultUserAgent = "skaterxl-map-manager"
+
+// DefaultTimeout bounds catalog requests.
+const DefaultTimeout = 30 * time.Second
+
+// DefaultHeaderTimeout bounds how long any request waits for the server to start answering.
+const DefaultHeaderTimeout = 30 * time.Second
+
+// DefaultIdleTimeout bounds how long a download may go without receiving data. Downloads have no overall
+// timeout, since large maps on slow connections take long, but a stalled connection fails instead of hanging.
+const DefaultIdleTimeout = 60 * time.Second
+
+// Client talks to the map catalog API and downloads map files.
+type Client struct {
+	// HTTPClient performs the requests. http.DefaultClient is used when nil.
+	HTTPClient *http.Client
+	// BaseURL is the API root, for example DefaultBaseURL or a mirror.
+	BaseURL string
+	// UserAgent is sent as the User-Agent header.
+	UserAgent string
+	// Timeout bounds catalog requests. Zero disables the timeout.
+	Timeout time.Duration
+	// IdleTimeout fails a download that receives no data for this long. Zero disables the timeout.
+	IdleTimeout time.Duration
+	// CacheDir holds the cached catalog. Empty disables caching.
+	CacheDir string
+	// Offline serves the catalog from the cache without contacting the server.
+	Offline bool
+}
+
+// NewClient returns a client for baseURL with the default user agent and timeouts.
+// An empty baseURL selects DefaultBaseURL.
+func NewClient(baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		HTTPClient:  &http.Client{Transport: NewTransport(DefaultHeaderTimeout)},
+		BaseURL:     baseURL,
+		UserAgent:   DefaultUserAgent,
+		Timeout:     DefaultTimeout,
+		IdleTimeout: DefaultIdleTimeout,
+	}
+}
+
+// NewTransport returns an HTTP transport that fails requests when the server takes longer than headerTimeout
+// to send the response headers. Zero disables the timeout.
+func NewTransport(headerTimeout time.Duration) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = headerTimeout
+	return transport
+}
+
+// StatusError is returned by Download when the server answers with a non-2xx status.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("bad status: %s", e.Status)
+}
+
+// ErrStalled is returned when reading a download body that received no data for the client's IdleTimeout.
+var ErrStalled = errors.New("download stalled")
+
+// Download starts a GET request for a map file and returns the response.
+// The caller must close the body. Non-2xx statuses are returned as *StatusError.
+// Reading the body fails with ErrStalled when no data arrives for IdleTimeout.
+func (c *Client) Download(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	req, err := c.newRequest(ctx, url)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("error creating download request: %w", err)
+	}
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		cancel()
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	resp.Body = newIdleReader(resp.Body, c.IdleTimeout, cancel)
+	return resp, nil
+}
+
+// idleReader cancels the request of a response body that receives no data for timeout, which unblocks the
+// pending read, and reports ErrStalled from then on.
+type idleReader struct {
+	body    io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	stalled atomic.Bool
+}
+
+func newIdleReader(body io.ReadCloser, timeout time.Duration, cancel context.CancelFunc) *idleReader {
+	r := &idleReader{body: body, timeout: timeout, cancel: cancel}
+	if timeout > 0 {
+		r.timer = time.AfterFunc(timeout, func() {
+			r.stalled.Store(true)
+			cancel()
+		})
+	}
+	return r
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	if r.stalled.Load() {
+		return n, fmt.Errorf("%w: no data received for %s", ErrStalled, r.timeout)
+	}
+	if n > 0 && r.timer != nil {
+		r.timer.Reset(r.timeout)
+	}
+	return n, err
+}
+
+func (r *idleReader) Close() error {
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	err := r.body.Close()
+	r.cancel()
+	return err
+}
+
+func (c *Client) newRequest(ctx context.Context, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	userAgent := c.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+	return req, nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (c *Client) mapsURL() string {
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return strings.TrimRight(baseURL, "/") + "/maps"
+}
+
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.Timeout)
+}
@@ -1,11 +1,14 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -16,12 +19,18 @@ type DownloadInfo struct {
 	DateExpires int64  `json:"date_expires"`
 }
 
+type Filehash struct {
+	MD5 string `json:"md5"`
+}
+
 type Modfile struct {
 	ID        int          `json:"id"`
 	Filename  string       `json:"filename"`
 	Version   string       `json:"version"`
 	Filesize  int          `json:"filesize"`
+	Filehash  Filehash     `json:"filehash"`
 	Download  DownloadInfo `json:"download"`
+	Changelog string       `json:"changelog"`
 }
 
 type Map struct {
@@ -72,17 +81,72 @@ type APIResponse struct {
 	Items       []Map     `json:"items"`
 }
 
-const APIEndpoint = "https://api.skatebit.app/api/v1/skaterxl/maps"
+// ErrOffline is returned when the catalog is needed but neither the server nor the cache can provide it.
+var ErrOffline = errors.New("catalog is not available offline")
+
+// FetchMaps downloads the full map catalog, falling back to the cache when the server is unreachable.
+func (c *Client) FetchMaps(ctx context.Context) ([]Map, error) {
+	catalog, err := c.FetchCatalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return catalog.Maps, nil
+}
+
+// FetchCatalog returns the map catalog. When a cache directory is configured the previous response is
+// revalidated with If-None-Match/If-Modified-Since and served from disk if the server is unreachable.
+func (c *Client) FetchCatalog(ctx context.Context) (*Catalog, error) {
+	cached := c.loadCatalogCache()
+	if c.Offline {
+		if cached == nil {
+			return nil, ErrOffline
+		}
+		Logger.Printf("Offline mode: using cached catalog from %s.", cached.FetchedAt)
+		return cached.catalog(true), nil
+	}
+
+	catalog, err := c.fetchCatalog(ctx, cached)
+	if err != nil && cached != nil {
+		Logger.Printf("Falling back to cached catalog from %s: %v", cached.FetchedAt, err)
+		return cached.catalog(true), nil
+	}
+	return catalog, err
+}
+
+func (c *Client) fetchCatalog(ctx context.Context, cached *catalogCacheEntry) (*Catalog, error) {
+	endpoint := c.mapsURL()
+	Logger.Println("Fetching maps from API:", endpoint)
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
 
-func FetchMaps() ([]Map, error) {
-	Logger.Println("Fetching maps from API:", APIEndpoint)
-	resp, err := http.Get(APIEndpoint)
+	req, err := c.newRequest(ctx, endpoint)
 	if err != nil {
-		Logger.Printf("Error during HTTP GET to %s: %v", APIEndpoint, err)
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		Logger.Printf("Error during HTTP GET to %s: %v", endpoint, err)
 		return nil, fmt.Errorf("error fetching maps: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		Logger.Printf("Catalog not modified since %s, using cache.", cached.FetchedAt)
+		cached.FetchedAt = time.Now().UTC()
+		c.saveCatalogCache(cached)
+		return cached.catalog(false), nil
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		Logger.Printf("API returned non-OK status: %s. Response Body (first 500 chars): %s", resp.Status, string(bodyBytes)[:min(len(bodyBytes), 500)])
@@ -103,7 +167,19 @@ func FetchMaps() ([]Map, error) {
 	}
 
 	Logger.Printf("Successfully fetched %d maps from API.", apiResponse.Count)
-	return apiResponse.Items, nil
+	entry := &catalogCacheEntry{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    time.Now().UTC(),
+		Response:     apiResponse,
+	}
+	c.saveCatalogCache(entry)
+
+	return &Catalog{
+		Maps:        apiResponse.Items,
+		LastUpdated: apiResponse.LastUpdated,
+		FetchedAt:   entry.FetchedAt,
+	}, nil
 }
 
 func min(a, b int) int {
@@ -111,4 +187,18 @@ func min(a, b int) int {
 		return a
 	}
 	return b
-}
\ No newline at end of file
+}
+
+// FilterConsoleMaps drops catalog entries that are console ports and cannot be installed on PC.
+func FilterConsoleMaps(maps []Map) []Map {
+	var filteredMaps []Map
+	for _, mapData := range maps {
+		lowerCaseName := strings.ToLower(mapData.Name)
+		if !strings.Contains(lowerCaseName, "ps4") &&
+			!strings.Contains(lowerCaseName, "playstation") &&
+			!strings.Contains(lowerCaseName, "xbox") {
+			filteredMaps = append(filteredMaps, mapData)
+		}
+	}
+	return filteredMaps
+}
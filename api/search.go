@@ -0,0 +1,353 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Query is a parsed map search. Free text is fuzzy-matched against the name, author, summary and tags of a
+// map; field filters narrow the results further:
+//
+//	author:name     the author's username contains name
+//	tag:name        a tag name contains name
+//	downloads>1000  compare total downloads (>, >=, <, <= or =; 2k and 1m are accepted)
+//	subscribers<50  compare subscribers the same way
+//
+// Values with spaces can be quoted, as in author:"some one".
+type Query struct {
+	terms   []string
+	authors []string
+	tags    []string
+	numbers []numberFilter
+}
+
+type numberFilter struct {
+	field string
+	op    string
+	value int
+}
+
+// SearchMatch describes how a map matched a query, for ranking and highlighting.
+type SearchMatch struct {
+	Score  int
+	Name   []int    // Rune positions in Name matched by the free text
+	Author []int    // Rune positions in SubmittedBy.Username matched by the free text or author:
+	Tags   []string // Tags matched by the free text or tag:
+}
+
+// SearchResult is a map matching a query.
+type SearchResult struct {
+	Map   Map
+	Match *SearchMatch
+}
+
+var numberFields = map[string]func(m Map) int{
+	"downloads":   func(m Map) int { return m.Stats.DownloadsTotal },
+	"subscribers": func(m Map) int { return m.Stats.SubscribersTotal },
+}
+
+// ParseQuery parses a search query. Filters without a value yet, such as a half typed "author:", are ignored
+// so that a query can be parsed on every keystroke.
+func ParseQuery(s string) (*Query, error) {
+	q := &Query{}
+	for _, token := range tokenize(s) {
+		lower := strings.ToLower(token)
+		switch {
+		case strings.HasPrefix(lower, "author:"):
+			if value := unquote(token[len("author:"):]); value != "" {
+				q.authors = append(q.authors, strings.ToLower(value))
+			}
+		case strings.HasPrefix(lower, "tag:"):
+			if value := unquote(token[len("tag:"):]); value != "" {
+				q.tags = append(q.tags, strings.ToLower(value))
+			}
+		default:
+			filter, ok, err := parseNumberFilter(lower)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				if filter.op != "" {
+					q.numbers = append(q.numbers, filter)
+				}
+				continue
+			}
+			if value := unquote(token); value != "" {
+				q.terms = append(q.terms, strings.ToLower(value))
+			}
+		}
+	}
+	return q, nil
+}
+
+// tokenize splits a query on spaces outside double quotes, keeping the quotes.
+func tokenize(s string) []string {
+	var tokens []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+func unquote(s string) string {
+	return strings.TrimSpace(strings.ReplaceAll(s, `"`, ""))
+}
+
+// parseNumberFilter parses tokens such as "downloads>=2k". ok is false for tokens that are not number filters;
+// a filter without a value yet is returned with an empty op.
+func parseNumberFilter(token string) (filter numberFilter, ok bool, err error) {
+	for field := range numberFields {
+		rest, found := strings.CutPrefix(token, field)
+		if !found {
+			continue
+		}
+		for _, op := range []string{">=", "<=", ">", "<", "="} {
+			value, found := strings.CutPrefix(rest, op)
+			if !found {
+				continue
+			}
+			if value == "" {
+				return numberFilter{}, true, nil
+			}
+			n, err := parseCount(value)
+			if err != nil {
+				return numberFilter{}, true, fmt.Errorf("invalid value %q for %s (expected a number such as 1000 or 2k)", value, field)
+			}
+			return numberFilter{field: field, op: op, value: n}, true, nil
+		}
+	}
+	return numberFilter{}, false, nil
+}
+
+// parseCount parses a non-negative count with an optional k (thousand) or m (million) suffix.
+func parseCount(s string) (int, error) {
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(s, "k"):
+		multiplier, s = 1e3, strings.TrimSuffix(s, "k")
+	case strings.HasSuffix(s, "m"):
+		multiplier, s = 1e6, strings.TrimSuffix(s, "m")
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid count %q", s)
+	}
+	return int(f * multiplier), nil
+}
+
+// Empty reports whether the query has neither free text nor filters, so every map matches.
+func (q *Query) Empty() bool {
+	return len(q.terms) == 0 && len(q.authors) == 0 && len(q.tags) == 0 && len(q.numbers) == 0
+}
+
+// Ranked reports whether the query has free text, by which results are ranked.
+func (q *Query) Ranked() bool {
+	return len(q.terms) > 0
+}
+
+// Match reports whether m matches every part of the query and how.
+func (q *Query) Match(m Map) (*SearchMatch, bool) {
+	match := &SearchMatch{}
+	for _, f := range q.numbers {
+		if !compare(numberFields[f.field](m), f.op, f.value) {
+			return nil, false
+		}
+	}
+	author := strings.ToLower(m.SubmittedBy.Username)
+	for _, a := range q.authors {
+		i := strings.Index(author, a)
+		if i < 0 {
+			return nil, false
+		}
+		start := len([]rune(author[:i]))
+		for j := range []rune(a) {
+			match.Author = append(match.Author, start+j)
+		}
+	}
+	for _, t := range q.tags {
+		tag, ok := findTag(m, func(name string) bool { return strings.Contains(strings.ToLower(name), t) })
+		if !ok {
+			return nil, false
+		}
+		match.Tags = appendUnique(match.Tags, tag)
+	}
+	for _, term := range q.terms {
+		if !matchTerm(term, m, match) {
+			return nil, false
+		}
+	}
+	return match, true
+}
+
+// matchTerm fuzzy-matches one word of free text against the fields of m, adding the best score and the
+// positions to highlight to match. Between equally good matches, name matches rank above author and tag
+// matches, which rank above the summary.
+func matchTerm(term string, m Map, match *SearchMatch) bool {
+	best := 0
+	if score, positions, ok := fuzzyMatch(term, m.Name); ok {
+		best = max(best, score+20)
+		match.Name = mergePositions(match.Name, positions)
+	}
+	if score, positions, ok := fuzzyMatch(term, m.SubmittedBy.Username); ok {
+		best = max(best, score+10)
+		match.Author = mergePositions(match.Author, positions)
+	}
+	for _, tag := range m.Tags {
+		if score, _, ok := fuzzyMatch(term, tag.Name); ok {
+			best = max(best, score+10)
+			match.Tags = appendUnique(match.Tags, tag.Name)
+		}
+	}
+	// Matching the summary word by word keeps a short term from matching scattered letters of a long text.
+	for _, word := range strings.FieldsFunc(m.Summary, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		if score, _, ok := fuzzyMatch(term, word); ok {
+			best = max(best, score)
+		}
+	}
+	if best == 0 {
+		return false
+	}
+	match.Score += best
+	return true
+}
+
+func compare(n int, op string, value int) bool {
+	switch op {
+	case ">":
+		return n > value
+	case ">=":
+		return n >= value
+	case "<":
+		return n < value
+	case "<=":
+		return n <= value
+	default:
+		return n == value
+	}
+}
+
+func findTag(m Map, fn func(name string) bool) (string, bool) {
+	for _, tag := range m.Tags {
+		if fn(tag.Name) {
+			return tag.Name, true
+		}
+	}
+	return "", false
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, existing := range list {
+		if existing == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+func mergePositions(a, b []int) []int {
+	seen := make(map[int]bool, len(a))
+	for _, p := range a {
+		seen[p] = true
+	}
+	for _, p := range b {
+		if !seen[p] {
+			a = append(a, p)
+		}
+	}
+	sort.Ints(a)
+	return a
+}
+
+// Search returns the maps matching q. Ranked queries order the results by score, keeping the order of maps
+// for equal scores; other queries keep the order of maps.
+func Search(maps []Map, q *Query) []SearchResult {
+	var results []SearchResult
+	for _, m := range maps {
+		if match, ok := q.Match(m); ok {
+			results = append(results, SearchResult{Map: m, Match: match})
+		}
+	}
+	if q.Ranked() {
+		sort.SliceStable(results, func(i, j int) bool { return results[i].Match.Score > results[j].Match.Score })
+	}
+	return results
+}
+
+// fuzzyMatch reports whether the letters of pattern appear in text in order, ignoring case. It returns a
+// score that favours consecutive letters, letters at the start of words and short spans, along with the rune
+// positions of the matched letters in text.
+func fuzzyMatch(pattern, text string) (int, []int, bool) {
+	p := []rune(pattern)
+	original := []rune(text)
+	if len(p) == 0 || len(original) == 0 {
+		return 0, nil, false
+	}
+	t := make([]rune, len(original))
+	for i, r := range original {
+		t[i] = unicode.ToLower(r)
+	}
+
+	// Find the first place the whole pattern matches, then walk back from there to the tightest start.
+	end, pi := -1, 0
+	for i := 0; i < len(t) && pi < len(p); i++ {
+		if t[i] == p[pi] {
+			pi++
+			if pi == len(p) {
+				end = i
+			}
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+	positions := make([]int, len(p))
+	pi = len(p) - 1
+	for i := end; i >= 0 && pi >= 0; i-- {
+		if t[i] == p[pi] {
+			positions[pi] = i
+			pi--
+		}
+	}
+
+	score := 0
+	for k, pos := range positions {
+		score += 16
+		if pos == 0 || !isWordRune(original[pos-1]) || (unicode.IsUpper(original[pos]) && unicode.IsLower(original[pos-1])) {
+			score += 8
+		}
+		if k > 0 {
+			if gap := pos - positions[k-1] - 1; gap == 0 {
+				score += 12
+			} else {
+				score -= min(gap, 8)
+			}
+		}
+	}
+	if positions[0] == 0 {
+		score += 8
+	}
+	return max(score, 1), positions, true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
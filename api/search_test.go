@@ -0,0 +1,220 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testMap builds a catalog entry with the fields the search looks at.
+func testMap(name, author, summary string, downloads int, tags ...string) Map {
+	m := Map{Name: name, Summary: summary}
+	m.SubmittedBy.Username = author
+	m.Stats.DownloadsTotal = downloads
+	for _, tag := range tags {
+		m.Tags = append(m.Tags, struct {
+			ID   int    `json:"id"`
+			Name string `json:"name"`
+		}{Name: tag})
+	}
+	return m
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Query
+		wantErr bool
+	}{
+		{input: "cool park", want: Query{terms: []string{"cool", "park"}}},
+		{input: `"Skate Park"`, want: Query{terms: []string{"skate park"}}},
+		{input: "author:Shawn", want: Query{authors: []string{"shawn"}}},
+		{input: `AUTHOR:"Some One"`, want: Query{authors: []string{"some one"}}},
+		{input: `tag:street tag:"Real Spot"`, want: Query{tags: []string{"street", "real spot"}}},
+		{input: "downloads>1000", want: Query{numbers: []numberFilter{{field: "downloads", op: ">", value: 1000}}}},
+		{input: "subscribers<=2k", want: Query{numbers: []numberFilter{{field: "subscribers", op: "<=", value: 2000}}}},
+		{input: "downloads=1.5m", want: Query{numbers: []numberFilter{{field: "downloads", op: "=", value: 1500000}}}},
+		{
+			input: `park author:shawn downloads>=2k tag:"real spot"`,
+			want: Query{
+				terms:   []string{"park"},
+				authors: []string{"shawn"},
+				tags:    []string{"real spot"},
+				numbers: []numberFilter{{field: "downloads", op: ">=", value: 2000}},
+			},
+		},
+		{input: "downloadsabc", want: Query{terms: []string{"downloadsabc"}}},
+		// Filters still being typed are ignored rather than rejected.
+		{input: "tag:", want: Query{}},
+		{input: `author:""`, want: Query{}},
+		{input: "downloads>", want: Query{}},
+		{input: "   ", want: Query{}},
+		{input: "downloads>abc", wantErr: true},
+		{input: "downloads>-5", wantErr: true},
+		{input: "subscribers<2x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseQuery(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseQuery(%q) = %+v, want an error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseQuery(%q) error = %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("ParseQuery(%q) = %+v, want %+v", tt.input, *got, tt.want)
+			}
+			if got.Empty() != reflect.DeepEqual(tt.want, Query{}) {
+				t.Errorf("Empty() = %v for %+v", got.Empty(), *got)
+			}
+		})
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tests := map[string][]string{
+		"cool  park":               {"cool", "park"},
+		`author:"some one" park`:   {`author:"some one"`, "park"},
+		`"unterminated quote here`: {`"unterminated quote here`},
+		"":                         nil,
+	}
+	for input, want := range tests {
+		if got := tokenize(input); !reflect.DeepEqual(got, want) {
+			t.Errorf("tokenize(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		pattern, text string
+		positions     []int // nil when the pattern does not match
+	}{
+		{"park", "Cool Park", []int{5, 6, 7, 8}},
+		{"cp", "Cool Park", []int{0, 5}},
+		{"pk", "Park", []int{0, 3}},
+		{"ab", "a_xab", []int{3, 4}}, // The tightest span wins over the first letter found
+		{"é", "Café", []int{3}},
+		{"ab", "ba", nil},
+		{"parks", "Park", nil},
+		{"", "Park", nil},
+		{"park", "", nil},
+	}
+
+	for _, tt := range tests {
+		score, positions, ok := fuzzyMatch(tt.pattern, tt.text)
+		if ok != (tt.positions != nil) {
+			t.Errorf("fuzzyMatch(%q, %q) ok = %v, want %v", tt.pattern, tt.text, ok, tt.positions != nil)
+			continue
+		}
+		if !reflect.DeepEqual(positions, tt.positions) {
+			t.Errorf("fuzzyMatch(%q, %q) positions = %v, want %v", tt.pattern, tt.text, positions, tt.positions)
+		}
+		if ok && score <= 0 {
+			t.Errorf("fuzzyMatch(%q, %q) score = %d, want a positive score", tt.pattern, tt.text, score)
+		}
+	}
+
+	// Consecutive letters at the start of a word score above scattered ones.
+	tight, _, _ := fuzzyMatch("park", "Park Lane")
+	later, _, _ := fuzzyMatch("park", "Skate Park")
+	scattered, _, _ := fuzzyMatch("park", "Plaza Rink")
+	if !(tight > later && later > scattered) {
+		t.Errorf("scores Park Lane %d, Skate Park %d, Plaza Rink %d, want them in decreasing order", tight, later, scattered)
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	maps := []Map{
+		testMap("Plaza Rink", "someone", "", 100),
+		testMap("Hidden Spot", "someone", "A park hidden away", 100),
+		testMap("DIY Spot", "Parker", "", 100),
+		testMap("Skate Park", "someone", "", 100),
+		testMap("Park Lane", "someone", "", 100),
+		testMap("Warehouse", "someone", "", 100),
+	}
+	q, err := ParseQuery("park")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, result := range Search(maps, q) {
+		names = append(names, result.Map.Name)
+	}
+	want := []string{"Park Lane", "Skate Park", "DIY Spot", "Hidden Spot", "Plaza Rink"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("Search(park) = %q, want %q", names, want)
+	}
+}
+
+func TestSearchMatches(t *testing.T) {
+	maps := []Map{
+		testMap("Cool Park", "Parker", "", 5000, "Street", "Real Spot"),
+		testMap("DIY Spot", "someone", "", 50, "Park"),
+	}
+	tests := []struct {
+		query string
+		want  []string // Names of the matching maps, in order
+		match SearchMatch
+	}{
+		{
+			query: "cool park",
+			want:  []string{"Cool Park"},
+			match: SearchMatch{Name: []int{0, 1, 2, 3, 5, 6, 7, 8}, Author: []int{0, 1, 2, 3}},
+		},
+		{
+			query: "author:ark",
+			want:  []string{"Cool Park"},
+			match: SearchMatch{Author: []int{1, 2, 3}},
+		},
+		{
+			query: `tag:"real spot"`,
+			want:  []string{"Cool Park"},
+			match: SearchMatch{Tags: []string{"Real Spot"}},
+		},
+		{
+			query: "downloads>=2k",
+			want:  []string{"Cool Park"},
+			match: SearchMatch{},
+		},
+		{
+			query: "downloads<2k tag:park",
+			want:  []string{"DIY Spot"},
+			match: SearchMatch{Tags: []string{"Park"}},
+		},
+		{
+			query: "author:nobody",
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			results := Search(maps, q)
+			var names []string
+			for _, result := range results {
+				names = append(names, result.Map.Name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Fatalf("Search(%q) = %q, want %q", tt.query, names, tt.want)
+			}
+			if len(results) == 0 {
+				return
+			}
+			got := *results[0].Match
+			got.Score = 0
+			if !reflect.DeepEqual(got, tt.match) {
+				t.Errorf("Search(%q) match = %+v, want %+v", tt.query, got, tt.match)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"sort"
+	"strings"
+)
+
+// TagCount is a catalog tag and the number of maps carrying it.
+type TagCount struct {
+	Name  string
+	Count int
+}
+
+// CountTags lists every tag used by maps, most used first. Tags differing only in case are counted together
+// under the first spelling seen.
+func CountTags(maps []Map) []TagCount {
+	index := map[string]int{}
+	var counts []TagCount
+	for _, m := range maps {
+		seen := map[string]bool{}
+		for _, tag := range m.Tags {
+			key := strings.ToLower(strings.TrimSpace(tag.Name))
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			i, ok := index[key]
+			if !ok {
+				i = len(counts)
+				index[key] = i
+				counts = append(counts, TagCount{Name: strings.TrimSpace(tag.Name)})
+			}
+			counts[i].Count++
+		}
+	}
+	sort.SliceStable(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return strings.ToLower(counts[i].Name) < strings.ToLower(counts[j].Name)
+	})
+	return counts
+}
+
+// TagFilter keeps the maps carrying every included tag and none of the excluded ones, like the tag filter of
+// mod.io. Tag names are compared ignoring case.
+type TagFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// Empty reports whether the filter keeps every map.
+func (f TagFilter) Empty() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+// Match reports whether m passes the filter.
+func (f TagFilter) Match(m Map) bool {
+	for _, tag := range f.Include {
+		if !HasTag(m, tag) {
+			return false
+		}
+	}
+	for _, tag := range f.Exclude {
+		if HasTag(m, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns the maps passing the filter, in their original order.
+func (f TagFilter) Filter(maps []Map) []Map {
+	if f.Empty() {
+		return maps
+	}
+	var kept []Map
+	for _, m := range maps {
+		if f.Match(m) {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+// HasTag reports whether m carries the named tag, ignoring case.
+func HasTag(m Map, name string) bool {
+	for _, tag := range m.Tags {
+		if strings.EqualFold(strings.TrimSpace(tag.Name), strings.TrimSpace(name)) {
+			return true
+		}
+	}
+	return false
+}
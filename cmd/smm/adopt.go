@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/installer"
+)
+
+// adoptionRecord is the JSON representation of a proposed or completed adoption.
+type adoptionRecord struct {
+	Path    string `json:"path"`
+	MapID   int    `json:"map_id"`
+	Name    string `json:"name"`
+	Match   string `json:"match"`
+	Files   int    `json:"files"`
+	Size    int64  `json:"size"`
+	Version string `json:"version"` // Release recorded on adoption, "unknown" when it is not known
+	Adopted bool   `json:"adopted"`
+}
+
+func runAdopt(args []string) int {
+	fs := flag.NewFlagSet("adopt", flag.ContinueOnError)
+	dir := fs.String("dir", "", "Maps directory to scan (defaults to the configured directory)")
+	yes := fs.Bool("yes", false, "Adopt every match without asking")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 0 {
+		return usageError("smm adopt [-dir path] [-yes]")
+	}
+
+	mapsDir, err := resolveMapsDir(*dir)
+	if err != nil {
+		return out.errorf(err, "%v", err)
+	}
+	maps, err := fetchCatalog()
+	if err != nil {
+		return out.errorf(err, "Error fetching maps: %v", err)
+	}
+	manifest, err := installer.LoadManifest()
+	if err != nil {
+		return out.errorf(err, "Error loading install manifest: %v", err)
+	}
+	adoptions, unmatched, err := installer.ScanUntracked(mapsDir, maps, manifest)
+	if err != nil {
+		return out.errorf(err, "Error scanning maps directory: %v", err)
+	}
+
+	if !out.machine() {
+		if len(adoptions) == 0 {
+			out.infof("No untracked maps in %s match the catalog.", mapsDir)
+		} else {
+			w := tabwriter.NewWriter(out.w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "#\tFOUND\tMAP\tID\tMATCHED BY\tSIZE\tVERSION")
+			for i, adoption := range adoptions {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%s\t%s\n", i+1, filepath.Base(adoption.Path), adoption.Map.Name, adoption.Map.ID, adoption.Match, installer.FormatSize(adoption.Size), adoption.Version())
+			}
+			w.Flush()
+		}
+		if len(unmatched) > 0 {
+			names := make([]string, len(unmatched))
+			for i, path := range unmatched {
+				names[i] = filepath.Base(path)
+			}
+			out.infof("Not matched to a catalog map: %s", strings.Join(names, ", "))
+		}
+		if len(adoptions) == 0 {
+			return exitOK
+		}
+	}
+
+	selected := adoptions
+	switch {
+	case *yes:
+	case out.machine():
+		// Without -yes, machine readable output is a dry run.
+		selected = nil
+	default:
+		selected = confirmAdoptions(adoptions)
+	}
+
+	var recs []*installer.InstalledMap
+	var adoptErr error
+	if len(selected) > 0 {
+		recs, adoptErr = installer.Adopt(mapsDir, selected)
+	}
+	adopted := map[int]bool{}
+	for _, rec := range recs {
+		adopted[rec.MapID] = true
+	}
+
+	if out.machine() {
+		for _, adoption := range adoptions {
+			out.emit("adoption", adoptionRecord{
+				Path:    adoption.Path,
+				MapID:   adoption.Map.ID,
+				Name:    adoption.Map.Name,
+				Match:   adoption.Match,
+				Files:   len(adoption.Files),
+				Size:    adoption.Size,
+				Version: adoption.Version(),
+				Adopted: adopted[adoption.Map.ID],
+			})
+		}
+	}
+	if adoptErr != nil {
+		return out.errorf(adoptErr, "Error adopting maps: %v", adoptErr)
+	}
+	unknown := 0
+	for _, rec := range recs {
+		if rec.VersionUnknown {
+			unknown++
+		}
+	}
+	if len(recs) > 0 {
+		out.infof("Adopted %d maps.", len(recs))
+	}
+	if unknown > 0 {
+		out.infof("%d have an unknown version, so smm update skips them until they are reinstalled.", unknown)
+	}
+	return exitOK
+}
+
+// confirmAdoptions asks whether to adopt all matches, none, or to choose one by one, and returns the chosen ones.
+func confirmAdoptions(adoptions []*installer.Adoption) []*installer.Adoption {
+	in := bufio.NewReader(os.Stdin)
+	switch ask(in, fmt.Sprintf("Adopt these %d maps? [y]es, [n]o, [s]elect: ", len(adoptions))) {
+	case "y", "yes":
+		return adoptions
+	case "s", "select":
+		var selected []*installer.Adoption
+		for _, adoption := range adoptions {
+			answer := ask(in, fmt.Sprintf("Adopt %s as %s (%d)? [y/N]: ", filepath.Base(adoption.Path), adoption.Map.Name, adoption.Map.ID))
+			if answer == "y" || answer == "yes" {
+				selected = append(selected, adoption)
+			}
+		}
+		return selected
+	default:
+		return nil
+	}
+}
+
+// ask prints prompt and returns the trimmed, lowercased answer. End of input counts as no answer.
+func ask(in *bufio.Reader, prompt string) string {
+	fmt.Fprint(out.w, prompt)
+	line, _ := in.ReadString('\n')
+	return strings.ToLower(strings.TrimSpace(line))
+}
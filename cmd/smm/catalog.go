@@ -0,0 +1,180 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/api"
+	"github.com/ShawnEdgell/skaterxl-map-manager/installer"
+)
+
+func runList(args []string) int {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	installedOnly := fs.Bool("installed", false, "Only list maps installed by SMM")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	maps, err := fetchCatalog()
+	if err != nil {
+		return out.errorf(err, "Error fetching maps: %v", err)
+	}
+	manifest, err := installer.LoadManifest()
+	if err != nil {
+		return out.errorf(err, "Error loading install manifest: %v", err)
+	}
+
+	if *installedOnly {
+		var installed []api.Map
+		for _, m := range maps {
+			if manifest.Get(m.ID) != nil {
+				installed = append(installed, m)
+			}
+		}
+		maps = append(installed, localMaps(manifest)...)
+	}
+
+	sortByName(maps)
+	out.maps(maps, manifest)
+	return exitOK
+}
+
+func runSearch(args []string) int {
+	if len(args) == 0 {
+		return usageError("smm search <query>")
+	}
+	query, err := api.ParseQuery(strings.Join(args, " "))
+	if err != nil {
+		return out.errorf(fmt.Errorf("%w: %v", errUsage, err), "%v", err)
+	}
+
+	maps, err := fetchCatalog()
+	if err != nil {
+		return out.errorf(err, "Error fetching maps: %v", err)
+	}
+	manifest, err := installer.LoadManifest()
+	if err != nil {
+		return out.errorf(err, "Error loading install manifest: %v", err)
+	}
+
+	// Best matches first; maps matching filters alone are listed by name.
+	sortByName(maps)
+	var matches []api.Map
+	for _, result := range api.Search(maps, query) {
+		matches = append(matches, result.Map)
+	}
+	if len(matches) == 0 {
+		return out.errorf(errMapNotFound, "No maps match %q.", strings.Join(args, " "))
+	}
+
+	out.maps(matches, manifest)
+	return exitOK
+}
+
+// sortByName orders maps by name, ignoring case.
+func sortByName(maps []api.Map) {
+	sort.Slice(maps, func(i, j int) bool {
+		return strings.ToLower(maps[i].Name) < strings.ToLower(maps[j].Name)
+	})
+}
+
+func runInfo(args []string) int {
+	if len(args) != 1 {
+		return usageError("smm info <id>")
+	}
+
+	maps, err := fetchCatalog()
+	if err != nil {
+		return out.errorf(err, "Error fetching maps: %v", err)
+	}
+	m, err := findMapByID(maps, args[0])
+	if err != nil {
+		return out.errorf(err, "%v", err)
+	}
+	manifest, err := installer.LoadManifest()
+	if err != nil {
+		return out.errorf(err, "Error loading install manifest: %v", err)
+	}
+
+	if out.machine() {
+		out.emit("map", newMapRecord(m, manifest))
+		return exitOK
+	}
+
+	fmt.Printf("Name:        %s\n", m.Name)
+	fmt.Printf("ID:          %d\n", m.ID)
+	fmt.Printf("Author:      %s\n", m.SubmittedBy.Username)
+	fmt.Printf("Version:     %s (file %d, %s)\n", m.Modfile.Version, m.Modfile.ID, m.Modfile.Filename)
+	fmt.Printf("Size:        %s download\n", installer.FormatSize(int64(m.Modfile.Filesize)))
+	fmt.Printf("Downloads:   %d\n", m.Stats.DownloadsTotal)
+	fmt.Printf("Rating:      %s\n", m.Stats.RatingsDisplayText)
+	fmt.Printf("Added:       %s\n", formatUnix(m.DateAdded))
+	fmt.Printf("Updated:     %s\n", formatUnix(m.DateUpdated))
+	fmt.Printf("Profile:     %s\n", m.ProfileURL)
+	if rec := manifest.Get(m.ID); rec != nil {
+		fmt.Printf("Installed:   %s in %s (%s)\n", rec.InstalledVersion(), rec.Location(), installer.FormatSize(rec.DiskUsage()))
+		if !rec.Enabled() {
+			fmt.Printf("Disabled:    the game does not load it until it is enabled with smm enable %d\n", m.ID)
+		}
+		if rec.Pinned {
+			fmt.Printf("Pinned:      updates skip it until it is unpinned with smm unpin %d\n", m.ID)
+		}
+	}
+	if m.Summary != "" {
+		fmt.Printf("\n%s\n", m.Summary)
+	}
+	return exitOK
+}
+
+func installStatus(m api.Map, manifest *installer.Manifest) string {
+	rec := manifest.Get(m.ID)
+	status := ""
+	switch {
+	case rec == nil:
+		return ""
+	case rec.Source == installer.SourceLocal:
+		status = "installed from file"
+	case rec.VersionUnknown:
+		status = "installed, version unknown"
+	case installer.IsOutdated(rec, m):
+		status = "update available"
+	default:
+		status = "installed"
+	}
+	if !rec.Enabled() {
+		status += ", disabled"
+	}
+	if rec.Pinned {
+		status += ", pinned"
+	}
+	return status
+}
+
+// localMaps returns catalog style entries for the maps installed from local files, which the catalog does not list.
+func localMaps(manifest *installer.Manifest) []api.Map {
+	var maps []api.Map
+	for _, rec := range manifest.Maps {
+		if rec.Source == installer.SourceLocal {
+			maps = append(maps, api.Map{ID: rec.MapID, Name: rec.Name})
+		}
+	}
+	return maps
+}
+
+// mapSize returns the disk usage of an installed map, or the download size of one that is not installed.
+func mapSize(m api.Map, manifest *installer.Manifest) string {
+	if rec := manifest.Get(m.ID); rec != nil {
+		return installer.FormatSize(rec.DiskUsage())
+	}
+	return installer.FormatSize(int64(m.Modfile.Filesize))
+}
+
+func formatUnix(ts int64) string {
+	if ts == 0 {
+		return "unknown"
+	}
+	return time.Unix(ts, 0).Format("2006-01-02")
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/fatih/color"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/api"
+	"github.com/ShawnEdgell/skaterxl-map-manager/installer"
+)
+
+const (
+	exitOK        = 0
+	exitError     = 1
+	exitUsage     = 2
+	exitNotFound  = 3
+	exitNetwork   = 4
+	exitIntegrity = 5
+)
+
+const usageText = `Usage: smm [-debug] [-offline] [-output table|json|ndjson] [command] [arguments]
+
+Run smm without a command to open the interactive interface.
+
+Commands:
+  list [-installed]            List maps in the catalog
+  search <query>               Fuzzy search by name, author, summary or tag (author:, tag:, downloads>N)
+  info <id>                    Show details for a map
+  install [-dir path] <id>...  Install maps by ID
+  install -file path           Install a local archive or map folder (-name sets its name)
+  update [-dir path] [id...]   Update outdated installed maps (all if no IDs are given)
+  uninstall <id|name>...       Remove maps installed by SMM
+  rollback [-list] <id|name>   Restore the previous version of a map (-version picks another)
+  disable <id|name>...         Move maps out of the maps directory so the game skips them
+  enable <id|name>...          Move disabled maps back into the maps directory
+  pin <id|name>...             Keep maps at their installed version; updates skip them
+  unpin <id|name>...           Let updates replace pinned maps again
+  adopt [-dir path] [-yes]     Find maps installed by hand and let SMM manage them
+  profile create <name>        Save the maps enabled now as a profile (-replace overwrites)
+  profile apply <name>         Enable, disable and install maps to match a profile
+  profile list                 List saved profiles
+  profile delete <name>        Delete a profile
+  export <file|->              Write the installed maps to a JSON or TOML modlist (-format picks one)
+  import [-exact] <file>       Install, update and enable maps to match a modlist (-dry-run previews)
+  config get [key]             Print configuration values
+  config set <key> <value>     Change a configuration value
+  help                         Show this help
+`
+
+var (
+	errMapNotFound = errors.New("map not found")
+	errFetch       = errors.New("catalog unavailable")
+	errUsage       = errors.New("invalid usage")
+)
+
+// runCommand dispatches a non-interactive subcommand and returns the process exit code.
+func runCommand(args []string) int {
+	code := dispatch(args)
+	out.flush()
+	return code
+}
+
+func dispatch(args []string) int {
+	switch args[0] {
+	case "list":
+		return runList(args[1:])
+	case "search":
+		return runSearch(args[1:])
+	case "info":
+		return runInfo(args[1:])
+	case "install":
+		return runInstall(args[1:])
+	case "update":
+		return runUpdate(args[1:])
+	case "uninstall":
+		return runUninstall(args[1:])
+	case "rollback":
+		return runRollback(args[1:])
+	case "disable":
+		return runSetEnabled("disable", args[1:])
+	case "enable":
+		return runSetEnabled("enable", args[1:])
+	case "pin":
+		return runSetPinned("pin", args[1:])
+	case "unpin":
+		return runSetPinned("unpin", args[1:])
+	case "adopt":
+		return runAdopt(args[1:])
+	case "profile":
+		return runProfile(args[1:])
+	case "export":
+		return runExport(args[1:])
+	case "import":
+		return runImport(args[1:])
+	case "config":
+		return runConfig(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(usageText)
+		return exitOK
+	default:
+		code := out.errorf(errUsage, "Unknown command: %s", args[0])
+		if !out.machine() {
+			fmt.Fprint(os.Stderr, usageText)
+		}
+		return code
+	}
+}
+
+func usageError(usage string) int {
+	return out.errorf(errUsage, "usage: %s", usage)
+}
+
+// fetchCatalog downloads the catalog and drops console-only maps. A warning is printed when cached data is used.
+func fetchCatalog() ([]api.Map, error) {
+	catalog, err := apiClient.FetchCatalog(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errFetch, err)
+	}
+	if catalog.Offline && !out.machine() {
+		color.New(color.FgYellow).Fprintf(os.Stderr, "Offline: using cached catalog last refreshed %s.\n", catalog.AgeText())
+	}
+	return api.FilterConsoleMaps(catalog.Maps), nil
+}
+
+// findMapByID parses id and returns the matching catalog entry.
+func findMapByID(maps []api.Map, id string) (api.Map, error) {
+	mapID, err := strconv.Atoi(id)
+	if err != nil {
+		return api.Map{}, fmt.Errorf("%w: invalid map ID %q", errUsage, id)
+	}
+	for _, m := range maps {
+		if m.ID == mapID {
+			return m, nil
+		}
+	}
+	return api.Map{}, fmt.Errorf("%w: no map with ID %d in the catalog", errMapNotFound, mapID)
+}
+
+// exitCodeFor maps an error to the most specific exit code.
+func exitCodeFor(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errUsage):
+		return exitUsage
+	case errors.Is(err, errMapNotFound), errors.Is(err, installer.ErrNotInstalled), errors.Is(err, installer.ErrNoBackup),
+		errors.Is(err, installer.ErrNoProfile):
+		return exitNotFound
+	case errors.Is(err, errFetch):
+		return exitNetwork
+	case errors.As(err, new(*installer.IntegrityError)), errors.As(err, new(*installer.LimitError)):
+		return exitIntegrity
+	default:
+		return exitError
+	}
+}
+
+func runUninstall(args []string) int {
+	if len(args) == 0 {
+		return usageError("smm uninstall <id|name>...")
+	}
+
+	code := exitOK
+	for _, query := range args {
+		rec, err := installer.UninstallMap(query)
+		if err != nil {
+			code = out.failure("uninstall", api.Map{Name: query}, err, "Failed to uninstall %s: %v", query, err)
+			continue
+		}
+		out.result(newInstallResult("uninstall", api.Map{ID: rec.MapID, Name: rec.Name}, rec, nil))
+		out.infof("Uninstalled %s (%s)", rec.Name, rec.Location())
+	}
+	return code
+}
+
+// runSetEnabled disables or enables the given maps, depending on action.
+func runSetEnabled(action string, args []string) int {
+	if len(args) == 0 {
+		return usageError(fmt.Sprintf("smm %s <id|name>...", action))
+	}
+
+	code := exitOK
+	for _, query := range args {
+		rec, err := installer.SetEnabled(query, action == "enable")
+		if err != nil {
+			code = out.failure(action, api.Map{Name: query}, err, "Failed to %s %s: %v", action, query, err)
+			continue
+		}
+		out.result(newInstallResult(action, api.Map{ID: rec.MapID, Name: rec.Name}, rec, nil))
+		if rec.Enabled() {
+			out.infof("Enabled %s (%s)", rec.Name, rec.Location())
+		} else {
+			out.infof("Disabled %s (moved to %s)", rec.Name, rec.Location())
+		}
+	}
+	return code
+}
+
+// runSetPinned pins maps to their installed version or unpins them.
+func runSetPinned(action string, args []string) int {
+	if len(args) == 0 {
+		return usageError(fmt.Sprintf("smm %s <id|name>...", action))
+	}
+
+	code := exitOK
+	for _, query := range args {
+		rec, err := installer.SetPinned(query, action == "pin")
+		if err != nil {
+			code = out.failure(action, api.Map{Name: query}, err, "Failed to %s %s: %v", action, query, err)
+			continue
+		}
+		out.result(newInstallResult(action, api.Map{ID: rec.MapID, Name: rec.Name}, rec, nil))
+		if rec.Pinned {
+			out.infof("Pinned %s to version %s", rec.Name, rec.InstalledVersion())
+		} else {
+			out.infof("Unpinned %s; updates replace it again", rec.Name)
+		}
+	}
+	return code
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/config"
+)
+
+func runConfig(args []string) int {
+	if len(args) == 0 {
+		return usageError("smm config get [key] | smm config set <key> <value>")
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return out.errorf(err, "Error loading configuration: %v", err)
+	}
+
+	switch args[0] {
+	case "get":
+		keys := config.Keys()
+		if len(args) > 1 {
+			keys = args[1:]
+		}
+		for _, key := range keys {
+			value, err := cfg.Get(key)
+			if err != nil {
+				return out.errorf(fmt.Errorf("%w: %v", errUsage, err), "%v", err)
+			}
+			switch {
+			case out.machine():
+				out.emit("config", configEntry{Key: key, Value: value})
+			case len(args) > 1:
+				fmt.Println(value)
+			default:
+				fmt.Printf("%s = %s\n", key, value)
+			}
+		}
+		return exitOK
+
+	case "set":
+		if len(args) != 3 {
+			return usageError("smm config set <key> <value>")
+		}
+		if err := cfg.Set(args[1], args[2]); err != nil {
+			return out.errorf(fmt.Errorf("%w: %v", errUsage, err), "%v", err)
+		}
+		if err := config.SaveConfig(cfg); err != nil {
+			return out.errorf(err, "Error saving configuration: %v", err)
+		}
+		value, _ := cfg.Get(args[1])
+		out.emit("config", configEntry{Key: args[1], Value: value})
+		out.infof("%s = %s", args[1], value)
+		return exitOK
+
+	default:
+		return usageError("smm config get [key] | smm config set <key> <value>")
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/api"
+	"github.com/ShawnEdgell/skaterxl-map-manager/config"
+	"github.com/ShawnEdgell/skaterxl-map-manager/installer"
+)
+
+func runInstall(args []string) int {
+	fs := flag.NewFlagSet("install", flag.ContinueOnError)
+	dir := fs.String("dir", "", "Maps directory to install into (defaults to the configured directory)")
+	file := fs.String("file", "", "Install a local archive or map folder instead of a catalog map")
+	name := fs.String("name", "", "Name for the map installed with -file (defaults to the file name)")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if (fs.NArg() == 0) == (*file == "") {
+		return usageError("smm install [-dir path] <id>... | smm install [-dir path] -file path [-name name]")
+	}
+
+	mapsDir, err := resolveMapsDir(*dir)
+	if err != nil {
+		return out.errorf(err, "%v", err)
+	}
+	if *file != "" {
+		m, err := installer.LocalMap(*file, *name)
+		if err != nil {
+			return out.errorf(err, "%v", err)
+		}
+		return runQueue("install", mapsDir, func(q *installer.Queue) { q.AddLocal(m, *file) })
+	}
+	maps, err := fetchCatalog()
+	if err != nil {
+		return out.errorf(err, "Error fetching maps: %v", err)
+	}
+
+	code := exitOK
+	var toInstall []api.Map
+	for _, id := range fs.Args() {
+		m, err := findMapByID(maps, id)
+		if err != nil {
+			code = out.errorf(err, "%v", err)
+			continue
+		}
+		toInstall = append(toInstall, m)
+	}
+	if installCode := installAll("install", toInstall, mapsDir); installCode != exitOK {
+		code = installCode
+	}
+	return code
+}
+
+func runUpdate(args []string) int {
+	fs := flag.NewFlagSet("update", flag.ContinueOnError)
+	dir := fs.String("dir", "", "Maps directory to install into (defaults to the configured directory)")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	mapsDir, err := resolveMapsDir(*dir)
+	if err != nil {
+		return out.errorf(err, "%v", err)
+	}
+	maps, err := fetchCatalog()
+	if err != nil {
+		return out.errorf(err, "Error fetching maps: %v", err)
+	}
+	manifest, err := installer.LoadManifest()
+	if err != nil {
+		return out.errorf(err, "Error loading install manifest: %v", err)
+	}
+
+	wanted := map[string]bool{}
+	for _, id := range fs.Args() {
+		wanted[id] = true
+	}
+
+	pinned := reportPinned(manifest, maps, wanted)
+	updates := installer.CheckUpdates(manifest, maps)
+	if len(updates) == 0 && pinned > 0 {
+		out.infof("All other installed maps are up to date.")
+		return exitOK
+	}
+	if len(updates) == 0 {
+		out.infof("All installed maps are up to date.")
+		return exitOK
+	}
+
+	var toUpdate []api.Map
+	for _, update := range updates {
+		if len(wanted) > 0 && !wanted[fmt.Sprint(update.Latest.ID)] {
+			continue
+		}
+		out.infof("Updating %s: %s -> %s", update.Latest.Name, update.Installed.Version, update.Latest.Modfile.Version)
+		toUpdate = append(toUpdate, update.Latest)
+	}
+	return installAll("update", toUpdate, mapsDir)
+}
+
+// reportPinned notes the pinned maps that update skips although the catalog has a newer release. With IDs
+// given, only those maps are reported. It returns the number of maps reported.
+func reportPinned(manifest *installer.Manifest, maps []api.Map, wanted map[string]bool) int {
+	skipped := 0
+	for _, m := range maps {
+		rec := manifest.Get(m.ID)
+		if rec == nil || !rec.Pinned || !installer.IsOutdated(rec, m) {
+			continue
+		}
+		if len(wanted) > 0 && !wanted[fmt.Sprint(m.ID)] {
+			continue
+		}
+		out.infof("Skipping %s: pinned to version %s (run smm unpin %d to update it to %s)", rec.Name, rec.Version, m.ID, m.Modfile.Version)
+		skipped++
+	}
+	return skipped
+}
+
+// resolveMapsDir returns override if set, otherwise the configured maps directory.
+func resolveMapsDir(override string) (string, error) {
+	if override != "" {
+		return override, nil
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return "", fmt.Errorf("error loading configuration: %w", err)
+	}
+	if cfg.SkaterXLMapsDir == "" {
+		return "", fmt.Errorf("%w: no maps directory configured. Run 'smm config set skater_xl_maps_dir <path>' or pass -dir", errUsage)
+	}
+	return cfg.SkaterXLMapsDir, nil
+}
+
+// installAll installs maps on the configured number of workers and returns the exit code of the last failure.
+func installAll(action string, maps []api.Map, mapsDir string) int {
+	if len(maps) == 0 {
+		return exitOK
+	}
+	return runQueue(action, mapsDir, func(q *installer.Queue) {
+		for _, m := range maps {
+			q.Add(m)
+		}
+	})
+}
+
+// runQueue installs the maps that add puts on a new install queue. It prints a progress line per map every 10%,
+// or streams progress events in NDJSON mode, and returns the exit code of the last failure.
+func runQueue(action string, mapsDir string, add func(q *installer.Queue)) int {
+	concurrency := installer.DefaultConcurrency
+	if cfg, err := config.LoadConfig(); err == nil && cfg.DownloadConcurrency > 0 {
+		concurrency = cfg.DownloadConcurrency
+	}
+
+	queue := installer.NewQueue(context.Background(), apiClient, mapsDir, concurrency)
+	add(queue)
+	queue.Close()
+
+	code := exitOK
+	lastStep := map[string]int64{}
+	for event := range queue.Events() {
+		m := event.Map
+		switch event.Status {
+		case installer.StatusDownloading, installer.StatusVerifying, installer.StatusExtracting:
+			if event.Progress.Type != "" {
+				out.progress(m.ID, event.Progress)
+			}
+			if event.Progress.Total <= 0 {
+				out.infof("[%s] %s", m.Name, capitalize(string(event.Status)))
+				continue
+			}
+			key := fmt.Sprintf("%d/%s", m.ID, event.Progress.Type)
+			step := event.Progress.Current * 10 / event.Progress.Total
+			if last, ok := lastStep[key]; ok && step == last {
+				continue
+			}
+			lastStep[key] = step
+			out.infof("[%s] %s %3d%% (%d/%d bytes)", m.Name, capitalize(event.Progress.Type), step*10, event.Progress.Current, event.Progress.Total)
+		case installer.StatusDone:
+			out.result(newInstallResult(action, m, event.Result, nil))
+			out.infof("[%s] Installed (%s)", m.Name, event.Result.Layout)
+		case installer.StatusFailed:
+			code = out.failure(action, m, event.Err, "[%s] Failed to %s: %v", m.Name, action, event.Err)
+		}
+	}
+	return code
+}
+
+// capitalize upper-cases the first letter of a status or progress type for display, e.g. "downloading".
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
@@ -5,23 +5,33 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/fatih/color"
 
+	"github.com/ShawnEdgell/skaterxl-map-manager/api"
 	"github.com/ShawnEdgell/skaterxl-map-manager/config"
+	"github.com/ShawnEdgell/skaterxl-map-manager/installer"
 	"github.com/ShawnEdgell/skaterxl-map-manager/ui"
 )
 
 var appLogger *log.Logger
+var apiClient *api.Client
 var debug = flag.Bool("debug", false, "Enable debug logging to debug.log")
+var offline = flag.Bool("offline", false, "Use the cached catalog without contacting the API")
+var outputFormat = flag.String("output", formatTable, "Output format for commands: table, json or ndjson")
 
 func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usageText)
+		fmt.Fprintln(os.Stderr, "\nFlags:")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
-	fmt.Println("Launching Skater XL Map Manager...")
-
 	if *debug {
 		logFilePath := "debug.log"
 		logFile, err := tea.LogToFile(logFilePath, "debug")
@@ -36,17 +46,92 @@ func main() {
 		appLogger = log.New(ioutil.Discard, "", 0) // Discard logs if not in debug mode
 	}
 	ui.Logger = appLogger
-
+	installer.Logger = appLogger
+	api.Logger = appLogger
 
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		color.Red("Error loading configuration: %v\n", err)
 		cfg = &config.Config{}
 	}
+	apiClient = newAPIClient(cfg)
+	installer.DownloadDir = cfg.DownloadDir
+	if installer.DownloadDir == "" {
+		if downloadDir, err := installer.DefaultDownloadDir(); err == nil {
+			installer.DownloadDir = downloadDir
+		} else {
+			appLogger.Printf("Persistent downloads disabled: %v", err)
+		}
+	}
+	installer.BackupDir = cfg.BackupDir
+	if installer.BackupDir == "" {
+		if backupDir, err := installer.DefaultBackupDir(); err == nil {
+			installer.BackupDir = backupDir
+		} else {
+			appLogger.Printf("Backups disabled: %v", err)
+		}
+	}
+	if cfg.BackupVersions != 0 {
+		installer.BackupVersions = cfg.BackupVersions
+	}
+	installer.DisabledDir = cfg.DisabledDir
+	installer.Limits = newExtractLimits(cfg)
+
+	if args := flag.Args(); len(args) > 0 {
+		o, err := newOutput(*outputFormat)
+		if err != nil {
+			color.Red("%v\n", err)
+			os.Exit(exitUsage)
+		}
+		out = o
+		os.Exit(runCommand(args))
+	}
+
+	fmt.Println("Launching Skater XL Map Manager...")
 
-	p := tea.NewProgram(ui.NewModel(cfg), tea.WithAltScreen())
+	p := tea.NewProgram(ui.NewModel(cfg, apiClient), tea.WithAltScreen())
 
 	if _, err := p.Run(); err != nil {
 		appLogger.Fatalf("Alas, there's been an error: %v", err)
 	}
 }
+
+// newAPIClient builds the catalog client from the configured base URL, timeouts and offline setting.
+func newAPIClient(cfg *config.Config) *api.Client {
+	client := api.NewClient(cfg.APIBaseURL)
+	if cfg.RequestTimeoutSeconds > 0 {
+		client.Timeout = time.Duration(cfg.RequestTimeoutSeconds) * time.Second
+	}
+	if cfg.HeaderTimeoutSeconds > 0 {
+		client.HTTPClient = &http.Client{Transport: api.NewTransport(time.Duration(cfg.HeaderTimeoutSeconds) * time.Second)}
+	}
+	if cfg.IdleTimeoutSeconds > 0 {
+		client.IdleTimeout = time.Duration(cfg.IdleTimeoutSeconds) * time.Second
+	}
+	if cacheDir, err := api.DefaultCacheDir(); err == nil {
+		client.CacheDir = cacheDir
+	} else {
+		appLogger.Printf("Catalog cache disabled: %v", err)
+	}
+	client.Offline = cfg.Offline || *offline
+	return client
+}
+
+// newExtractLimits applies the configured extraction limits over installer.DefaultExtractLimits.
+// Zero keeps the default and a negative value disables the limit.
+func newExtractLimits(cfg *config.Config) installer.ExtractLimits {
+	limits := installer.DefaultExtractLimits
+	if cfg.ExtractMaxMB != 0 {
+		limits.MaxTotalBytes = int64(max(cfg.ExtractMaxMB, 0)) << 20
+	}
+	if cfg.ExtractMaxEntries != 0 {
+		limits.MaxEntries = max(cfg.ExtractMaxEntries, 0)
+	}
+	if cfg.ExtractMaxRatio != 0 {
+		limits.MaxRatio = float64(max(cfg.ExtractMaxRatio, 0))
+	}
+	if cfg.ExtractMaxDepth != 0 {
+		limits.MaxDepth = max(cfg.ExtractMaxDepth, 0)
+	}
+	return limits
+}
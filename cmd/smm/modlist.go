@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/api"
+	"github.com/ShawnEdgell/skaterxl-map-manager/installer"
+)
+
+// runExport writes the installed catalog maps to a modlist file, or to standard output for "-".
+func runExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "", "Modlist format, json or toml (defaults to the file extension)")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		return usageError("smm export [-format json|toml] <file|->")
+	}
+	path := fs.Arg(0)
+	if *format == "" {
+		*format = installer.ModlistFormat(path)
+	}
+
+	manifest, err := installer.LoadManifest()
+	if err != nil {
+		return out.errorf(err, "Error loading install manifest: %v", err)
+	}
+	list, skipped := installer.NewModlist(manifest)
+
+	var buf bytes.Buffer
+	if err := list.Encode(&buf, *format); err != nil {
+		return out.errorf(fmt.Errorf("%w: %v", errUsage, err), "%v", err)
+	}
+	if path == "-" {
+		// Machine formats frame their output, so the modlist goes out as a record rather than raw.
+		if out.machine() {
+			out.emit("modlist", list)
+			return exitOK
+		}
+		os.Stdout.Write(buf.Bytes())
+		return exitOK
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return out.errorf(err, "Error writing modlist: %v", err)
+	}
+	out.infof("Exported %d maps to %s.", len(list.Maps), path)
+	if skipped > 0 {
+		out.infof("Left out %d maps installed from local files, which others cannot download.", skipped)
+	}
+	return exitOK
+}
+
+// runImport reproduces the maps of a modlist: it installs, updates or restores each map as planned by
+// installer.PlanImport, then enables and disables maps to match the list.
+func runImport(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	dir := fs.String("dir", "", "Maps directory to install into (defaults to the configured directory)")
+	exact := fs.Bool("exact", false, "Also disable installed maps that are not in the modlist")
+	dryRun := fs.Bool("dry-run", false, "Show what would be done without changing anything")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		return usageError("smm import [-dir path] [-exact] [-dry-run] <file>")
+	}
+	path := fs.Arg(0)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return out.errorf(err, "Error reading modlist: %v", err)
+	}
+	format := ""
+	if installer.ModlistFormat(path) == installer.ModlistTOML {
+		format = installer.ModlistTOML
+	}
+	list, err := installer.DecodeModlist(data, format)
+	if err != nil {
+		return out.errorf(err, "Error reading modlist: %v", err)
+	}
+
+	maps, err := fetchCatalog()
+	if err != nil {
+		return out.errorf(err, "Error fetching maps: %v", err)
+	}
+	manifest, err := installer.LoadManifest()
+	if err != nil {
+		return out.errorf(err, "Error loading install manifest: %v", err)
+	}
+	steps := installer.PlanImport(list, maps, manifest)
+
+	if !out.machine() {
+		w := tabwriter.NewWriter(out.w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tVERSION\tENABLED\tACTION\tNOTE")
+		for _, step := range steps {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%t\t%s\t%s\n", step.Entry.MapID, step.Entry.Name, step.Entry.Version, step.Entry.Enabled, step.Action, step.Note)
+		}
+		w.Flush()
+	}
+	if *dryRun {
+		for _, step := range steps {
+			out.emit("import", newImportRecord(step))
+		}
+		return exitOK
+	}
+
+	mapsDir := *dir
+	code := exitOK
+	var toInstall []api.Map
+	for _, step := range steps {
+		switch step.Action {
+		case installer.ImportInstall, installer.ImportUpdate:
+			toInstall = append(toInstall, step.Map)
+		case installer.ImportRestore:
+			rec, err := installer.RestorePinned(step.Entry)
+			if err != nil {
+				code = out.failure("restore", step.Map, err, "[%s] Failed to restore version %s: %v", step.Entry.Name, step.Entry.Version, err)
+				continue
+			}
+			out.result(newInstallResult("restore", api.Map{ID: rec.MapID, Name: rec.Name}, rec, nil))
+			out.infof("[%s] Restored version %s from backup", rec.Name, rec.Version)
+		case installer.ImportSkip:
+			if rec := manifest.Get(step.Entry.MapID); rec != nil {
+				out.result(newInstallResult("skip", api.Map{ID: rec.MapID, Name: rec.Name}, rec, nil))
+			}
+		case installer.ImportUnavailable:
+			err := fmt.Errorf("%w: %s (%d) is %s", errMapNotFound, step.Entry.Name, step.Entry.MapID, step.Note)
+			code = out.failure("install", api.Map{ID: step.Entry.MapID, Name: step.Entry.Name}, err, "[%s] Cannot install: %s", step.Entry.Name, step.Note)
+		}
+	}
+	if len(toInstall) > 0 {
+		if mapsDir, err = resolveMapsDir(mapsDir); err != nil {
+			return out.errorf(err, "%v", err)
+		}
+		if installCode := installAll("install", toInstall, mapsDir); installCode != exitOK {
+			code = installCode
+		}
+	}
+
+	if switchCode := matchEnabled(list, *exact); switchCode != exitOK {
+		code = switchCode
+	}
+	if code == exitOK {
+		out.infof("Imported %d maps from %s.", len(list.Maps), path)
+	}
+	return code
+}
+
+// matchEnabled enables and disables the installed maps of the modlist as it records. With exact set, installed
+// maps that are not in the modlist are disabled too.
+func matchEnabled(list *installer.Modlist, exact bool) int {
+	manifest, err := installer.LoadManifest()
+	if err != nil {
+		return out.errorf(err, "Error loading install manifest: %v", err)
+	}
+	wanted := map[int]bool{}
+	listed := map[int]bool{}
+	for _, entry := range list.Maps {
+		wanted[entry.MapID] = entry.Enabled
+		listed[entry.MapID] = true
+	}
+
+	code := exitOK
+	for _, rec := range manifest.Maps {
+		enable := wanted[rec.MapID]
+		if (!listed[rec.MapID] && !exact) || rec.Enabled() == enable {
+			continue
+		}
+		action := "disable"
+		if enable {
+			action = "enable"
+		}
+		switched, err := installer.SetEnabled(fmt.Sprint(rec.MapID), enable)
+		if err != nil {
+			code = out.failure(action, api.Map{ID: rec.MapID, Name: rec.Name}, err, "[%s] Failed to %s: %v", rec.Name, action, err)
+			continue
+		}
+		out.result(newInstallResult(action, api.Map{ID: switched.MapID, Name: switched.Name}, switched, nil))
+		if enable {
+			out.infof("Enabled %s", switched.Name)
+		} else {
+			out.infof("Disabled %s", switched.Name)
+		}
+	}
+	return code
+}
+
+// importRecord is the JSON representation of a planned import step, emitted by import -dry-run.
+type importRecord struct {
+	MapID     int    `json:"map_id"`
+	Name      string `json:"name"`
+	ModfileID int    `json:"modfile_id"`
+	Version   string `json:"version,omitempty"`
+	Enabled   bool   `json:"enabled"`
+	Action    string `json:"action"`
+	Note      string `json:"note,omitempty"`
+}
+
+func newImportRecord(step *installer.ImportStep) importRecord {
+	return importRecord{
+		MapID:     step.Entry.MapID,
+		Name:      step.Entry.Name,
+		ModfileID: step.Entry.ModfileID,
+		Version:   step.Entry.Version,
+		Enabled:   step.Entry.Enabled,
+		Action:    step.Action,
+		Note:      step.Note,
+	}
+}
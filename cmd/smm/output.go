@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/api"
+	"github.com/ShawnEdgell/skaterxl-map-manager/installer"
+)
+
+// schemaVersion is bumped whenever a field in the JSON output is renamed, removed or changes meaning.
+const schemaVersion = 1
+
+const (
+	formatTable  = "table"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+)
+
+// envelope wraps every JSON document and NDJSON line written by SMM.
+type envelope struct {
+	SchemaVersion int          `json:"schema_version"`
+	Type          string       `json:"type"`
+	Data          any          `json:"data,omitempty"`
+	Error         *errorRecord `json:"error,omitempty"`
+}
+
+// mapRecord is the stable JSON representation of a catalog map.
+type mapRecord struct {
+	ID              int       `json:"id"`
+	Name            string    `json:"name"`
+	NameID          string    `json:"name_id"`
+	Author          string    `json:"author"`
+	Summary         string    `json:"summary"`
+	ProfileURL      string    `json:"profile_url"`
+	ModfileID       int       `json:"modfile_id"`
+	Version         string    `json:"version"`
+	Filename        string    `json:"filename"`
+	Filesize        int       `json:"filesize"`
+	Downloads       int       `json:"downloads"`
+	Subscribers     int       `json:"subscribers"`
+	Rating          string    `json:"rating"`
+	Tags            []string  `json:"tags"`
+	DateAdded       time.Time `json:"date_added"`
+	DateUpdated     time.Time `json:"date_updated"`
+	Installed       bool      `json:"installed"`
+	UpdateAvailable bool      `json:"update_available"`
+	DiskUsage       int64     `json:"disk_usage,omitempty"`      // Bytes used by the installed files
+	Disabled        bool      `json:"disabled,omitempty"`        // Installed but moved out of the maps directory
+	Pinned          bool      `json:"pinned,omitempty"`          // Kept at the installed version; updates skip it
+	VersionUnknown  bool      `json:"version_unknown,omitempty"` // Adopted without knowing the installed version
+}
+
+// installResult is the stable JSON representation of an install, update, uninstall or rollback.
+type installResult struct {
+	Action    string       `json:"action"`
+	MapID     int          `json:"map_id"`
+	Name      string       `json:"name"`
+	ModfileID int          `json:"modfile_id,omitempty"`
+	Version   string       `json:"version,omitempty"`
+	Folder    string       `json:"folder,omitempty"`
+	Files     int          `json:"files,omitempty"`
+	Layout    string       `json:"layout,omitempty"` // Where the map was found in the archive
+	Source    string       `json:"source,omitempty"` // "local" for maps installed with -file
+	Disabled  bool         `json:"disabled,omitempty"`
+	Pinned    bool         `json:"pinned,omitempty"`
+	OK        bool         `json:"ok"`
+	Error     *errorRecord `json:"error,omitempty"`
+}
+
+// progressEvent is streamed in NDJSON mode for every installer.ProgressMsg.
+type progressEvent struct {
+	MapID   int    `json:"map_id"`
+	Phase   string `json:"phase"`
+	Current int64  `json:"current"`
+	Total   int64  `json:"total"`
+}
+
+// configEntry is the JSON representation of a single configuration setting.
+type configEntry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type errorRecord struct {
+	Kind     string `json:"kind"`
+	Message  string `json:"message"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// output writes command results either as human readable text or in a machine readable format.
+type output struct {
+	format   string
+	w        io.Writer
+	dataType string
+	data     []any
+	errs     []*errorRecord
+}
+
+var out = &output{format: formatTable, w: os.Stdout}
+
+func newOutput(format string) (*output, error) {
+	switch format {
+	case formatTable, formatJSON, formatNDJSON:
+		return &output{format: format, w: os.Stdout}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q (expected table, json or ndjson)", format)
+	}
+}
+
+func (o *output) machine() bool { return o.format != formatTable }
+
+// infof prints a human readable progress line. Machine readable formats skip these.
+func (o *output) infof(format string, args ...any) {
+	if o.machine() {
+		return
+	}
+	fmt.Fprintf(o.w, format+"\n", args...)
+}
+
+// errorf reports err with a human readable message and returns the matching exit code.
+func (o *output) errorf(err error, format string, args ...any) int {
+	code := exitCodeFor(err)
+	message := fmt.Sprintf(format, args...)
+	switch o.format {
+	case formatNDJSON:
+		o.writeLine(envelope{SchemaVersion: schemaVersion, Type: "error", Error: newErrorRecord(err, message, code)})
+	case formatJSON:
+		o.errs = append(o.errs, newErrorRecord(err, message, code))
+	default:
+		color.Red("%s\n", message)
+	}
+	return code
+}
+
+// emit writes v immediately in NDJSON mode and buffers it for a single document in JSON mode.
+func (o *output) emit(kind string, v any) {
+	switch o.format {
+	case formatNDJSON:
+		o.writeLine(envelope{SchemaVersion: schemaVersion, Type: kind, Data: v})
+	case formatJSON:
+		o.dataType = kind
+		o.data = append(o.data, v)
+	}
+}
+
+func (o *output) writeLine(env envelope) {
+	line, err := json.Marshal(env)
+	if err != nil {
+		line, _ = json.Marshal(envelope{SchemaVersion: schemaVersion, Type: "error", Error: newErrorRecord(err, err.Error(), exitError)})
+	}
+	fmt.Fprintln(o.w, string(line))
+}
+
+// flush writes the buffered JSON document. It is a no-op in the other formats.
+func (o *output) flush() {
+	if o.format != formatJSON {
+		return
+	}
+	doc := struct {
+		SchemaVersion int            `json:"schema_version"`
+		Type          string         `json:"type"`
+		Data          []any          `json:"data"`
+		Errors        []*errorRecord `json:"errors,omitempty"`
+	}{schemaVersion, o.dataType, o.data, o.errs}
+	if doc.Type == "" {
+		doc.Type = "empty"
+	}
+	if doc.Data == nil {
+		doc.Data = []any{}
+	}
+
+	enc := json.NewEncoder(o.w)
+	enc.SetIndent("", "  ")
+	enc.Encode(doc)
+	o.data, o.errs = nil, nil
+}
+
+// maps writes catalog entries as a table or as map records, in the order given.
+func (o *output) maps(maps []api.Map, manifest *installer.Manifest) {
+	if o.machine() {
+		for _, m := range maps {
+			o.emit("map", newMapRecord(m, manifest))
+		}
+		return
+	}
+
+	w := tabwriter.NewWriter(o.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tAUTHOR\tDOWNLOADS\tSIZE\tSTATUS")
+	for _, m := range maps {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%s\n", m.ID, m.Name, m.SubmittedBy.Username, m.Stats.DownloadsTotal, mapSize(m, manifest), installStatus(m, manifest))
+	}
+	w.Flush()
+}
+
+// result records the outcome of an install, update or uninstall.
+func (o *output) result(r installResult) {
+	o.emit("result", r)
+}
+
+// failure records a failed install, update or uninstall of m and returns the exit code for err.
+func (o *output) failure(action string, m api.Map, err error, format string, args ...any) int {
+	if o.machine() {
+		o.result(newInstallResult(action, m, nil, err))
+	} else {
+		color.Red(format+"\n", args...)
+	}
+	return exitCodeFor(err)
+}
+
+// progress streams an installer progress message in NDJSON mode.
+func (o *output) progress(mapID int, msg installer.ProgressMsg) {
+	if o.format != formatNDJSON {
+		return
+	}
+	o.emit("progress", progressEvent{MapID: mapID, Phase: msg.Type, Current: msg.Current, Total: msg.Total})
+}
+
+func newMapRecord(m api.Map, manifest *installer.Manifest) mapRecord {
+	tags := make([]string, 0, len(m.Tags))
+	for _, tag := range m.Tags {
+		tags = append(tags, tag.Name)
+	}
+	rec := mapRecord{
+		ID:          m.ID,
+		Name:        m.Name,
+		NameID:      m.NameID,
+		Author:      m.SubmittedBy.Username,
+		Summary:     m.Summary,
+		ProfileURL:  m.ProfileURL,
+		ModfileID:   m.Modfile.ID,
+		Version:     m.Modfile.Version,
+		Filename:    m.Modfile.Filename,
+		Filesize:    m.Modfile.Filesize,
+		Downloads:   m.Stats.DownloadsTotal,
+		Subscribers: m.Stats.SubscribersTotal,
+		Rating:      m.Stats.RatingsDisplayText,
+		Tags:        tags,
+		DateAdded:   time.Unix(m.DateAdded, 0).UTC(),
+		DateUpdated: time.Unix(m.DateUpdated, 0).UTC(),
+	}
+	if manifest != nil {
+		if installed := manifest.Get(m.ID); installed != nil {
+			rec.Installed = true
+			rec.UpdateAvailable = installer.IsOutdated(installed, m)
+			rec.DiskUsage = installed.DiskUsage()
+			rec.Disabled = !installed.Enabled()
+			rec.Pinned = installed.Pinned
+			rec.VersionUnknown = installed.VersionUnknown
+		}
+	}
+	return rec
+}
+
+func newInstallResult(action string, m api.Map, rec *installer.InstalledMap, err error) installResult {
+	r := installResult{Action: action, MapID: m.ID, Name: m.Name, OK: err == nil}
+	if rec != nil {
+		r.ModfileID = rec.ModfileID
+		r.Version = rec.Version
+		r.Folder = rec.Folder
+		r.Files = len(rec.Files)
+		r.Layout = rec.Layout
+		r.Source = rec.Source
+		r.Disabled = !rec.Enabled()
+		r.Pinned = rec.Pinned
+	}
+	if err != nil {
+		r.Error = newErrorRecord(err, err.Error(), exitCodeFor(err))
+	}
+	return r
+}
+
+func newErrorRecord(err error, message string, code int) *errorRecord {
+	return &errorRecord{Kind: errorKind(err), Message: message, ExitCode: code}
+}
+
+// errorKind classifies errors into the stable kind strings of the JSON schema.
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, errMapNotFound):
+		return "map_not_found"
+	case errors.Is(err, installer.ErrNotInstalled):
+		return "not_installed"
+	case errors.Is(err, installer.ErrNoBackup):
+		return "no_backup"
+	case errors.Is(err, installer.ErrNoProfile):
+		return "profile_not_found"
+	case errors.Is(err, installer.ErrProfileExists):
+		return "profile_exists"
+	case errors.Is(err, installer.ErrModlistVersion):
+		return "modlist_version"
+	case errors.Is(err, errFetch):
+		return "network"
+	case errors.As(err, new(*installer.IntegrityError)):
+		return "integrity"
+	case errors.As(err, new(*installer.LimitError)):
+		return "limit"
+	case errors.As(err, new(*installer.DiskSpaceError)):
+		return "disk_space"
+	case errors.Is(err, errUsage):
+		return "usage"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/api"
+	"github.com/ShawnEdgell/skaterxl-map-manager/installer"
+)
+
+const profileUsage = "smm profile create [-replace] <name> | smm profile apply [-dir path] <name> | smm profile list | smm profile delete <name>"
+
+// profileRecord is the JSON representation of a saved profile.
+type profileRecord struct {
+	Name      string                 `json:"name"`
+	CreatedAt time.Time              `json:"created_at"`
+	Maps      []installer.ProfileMap `json:"maps"`
+	Active    bool                   `json:"active"` // Exactly the profile's maps are installed and enabled
+}
+
+func newProfileRecord(p *installer.Profile, manifest *installer.Manifest) profileRecord {
+	return profileRecord{Name: p.Name, CreatedAt: p.CreatedAt, Maps: p.Maps, Active: p.Active(manifest)}
+}
+
+func runProfile(args []string) int {
+	if len(args) == 0 {
+		return usageError(profileUsage)
+	}
+	switch args[0] {
+	case "create":
+		return createProfile(args[1:])
+	case "apply":
+		return applyProfile(args[1:])
+	case "list":
+		return listProfiles(args[1:])
+	case "delete":
+		return deleteProfile(args[1:])
+	default:
+		return usageError(profileUsage)
+	}
+}
+
+func createProfile(args []string) int {
+	fs := flag.NewFlagSet("profile create", flag.ContinueOnError)
+	replace := fs.Bool("replace", false, "Overwrite an existing profile with the same name")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		return usageError("smm profile create [-replace] <name>")
+	}
+
+	profile, err := installer.CreateProfile(fs.Arg(0), *replace)
+	if err != nil {
+		return out.errorf(err, "Error saving profile: %v", err)
+	}
+	manifest, err := installer.LoadManifest()
+	if err != nil {
+		return out.errorf(err, "Error loading install manifest: %v", err)
+	}
+	out.emit("profile", newProfileRecord(profile, manifest))
+	out.infof("Saved profile %s with the %d maps enabled now.", profile.Name, len(profile.Maps))
+	return exitOK
+}
+
+func listProfiles(args []string) int {
+	if len(args) != 0 {
+		return usageError("smm profile list")
+	}
+	profiles, err := installer.LoadProfiles()
+	if err != nil {
+		return out.errorf(err, "Error loading profiles: %v", err)
+	}
+	manifest, err := installer.LoadManifest()
+	if err != nil {
+		return out.errorf(err, "Error loading install manifest: %v", err)
+	}
+
+	if out.machine() {
+		for _, profile := range profiles {
+			out.emit("profile", newProfileRecord(profile, manifest))
+		}
+		return exitOK
+	}
+	if len(profiles) == 0 {
+		out.infof("No profiles. Save the maps enabled now with smm profile create <name>.")
+		return exitOK
+	}
+	w := tabwriter.NewWriter(out.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tMAPS\tCREATED\tSTATUS")
+	for _, profile := range profiles {
+		status := ""
+		if profile.Active(manifest) {
+			status = "active"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", profile.Name, len(profile.Maps), profile.CreatedAt.Local().Format("2006-01-02 15:04"), status)
+	}
+	w.Flush()
+	return exitOK
+}
+
+func deleteProfile(args []string) int {
+	if len(args) != 1 {
+		return usageError("smm profile delete <name>")
+	}
+	profile, err := installer.DeleteProfile(args[0])
+	if err != nil {
+		return out.errorf(err, "Error deleting profile: %v", err)
+	}
+	out.infof("Deleted profile %s. Installed maps were not changed.", profile.Name)
+	return exitOK
+}
+
+// applyProfile enables and disables maps to match the profile, then installs the profile's missing maps.
+func applyProfile(args []string) int {
+	fs := flag.NewFlagSet("profile apply", flag.ContinueOnError)
+	dir := fs.String("dir", "", "Maps directory to install missing maps into (defaults to the configured directory)")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		return usageError("smm profile apply [-dir path] <name>")
+	}
+
+	profile, err := installer.FindProfile(fs.Arg(0))
+	if err != nil {
+		return out.errorf(err, "%v", err)
+	}
+	plan, applyErr := installer.ApplyProfile(profile)
+	if plan == nil {
+		return out.errorf(applyErr, "Error applying profile: %v", applyErr)
+	}
+
+	code := exitOK
+	manifest, err := installer.LoadManifest()
+	if err != nil {
+		return out.errorf(err, "Error loading install manifest: %v", err)
+	}
+	for _, rec := range plan.Disable {
+		reportSwitch("disable", rec, manifest)
+	}
+	for _, rec := range plan.Enable {
+		reportSwitch("enable", rec, manifest)
+	}
+	if applyErr != nil {
+		code = out.errorf(applyErr, "Some maps could not be switched: %v", applyErr)
+	}
+
+	if len(plan.Missing) > 0 {
+		if installCode := installMissing(plan.Missing, *dir); installCode != exitOK {
+			code = installCode
+		}
+	}
+	if plan.Empty() {
+		out.infof("Profile %s is already active.", profile.Name)
+	} else if code == exitOK {
+		out.infof("Applied profile %s.", profile.Name)
+	}
+	return code
+}
+
+// reportSwitch reports a map the profile enabled or disabled. Maps that failed to switch are reported with
+// the error returned by ApplyProfile instead.
+func reportSwitch(action string, planned *installer.InstalledMap, manifest *installer.Manifest) {
+	rec := manifest.Get(planned.MapID)
+	if rec == nil || rec.Enabled() != (action == "enable") {
+		return
+	}
+	out.result(newInstallResult(action, api.Map{ID: rec.MapID, Name: rec.Name}, rec, nil))
+	if action == "enable" {
+		out.infof("Enabled %s", rec.Name)
+	} else {
+		out.infof("Disabled %s", rec.Name)
+	}
+}
+
+// installMissing installs the maps of a profile that are not installed. Maps the catalog no longer lists,
+// including maps installed from local files, cannot be installed and are reported instead.
+func installMissing(missing []installer.ProfileMap, dir string) int {
+	mapsDir, err := resolveMapsDir(dir)
+	if err != nil {
+		return out.errorf(err, "%v", err)
+	}
+	maps, err := fetchCatalog()
+	if err != nil {
+		return out.errorf(err, "Error fetching maps: %v", err)
+	}
+
+	code := exitOK
+	var toInstall []api.Map
+	for _, pm := range missing {
+		m, err := findMapByID(maps, strconv.Itoa(pm.MapID))
+		if err != nil {
+			code = out.failure("install", api.Map{ID: pm.MapID, Name: pm.Name}, err, "Cannot install %s: %v", pm.Name, err)
+			continue
+		}
+		toInstall = append(toInstall, m)
+	}
+	if installCode := installAll("install", toInstall, mapsDir); installCode != exitOK {
+		code = installCode
+	}
+	return code
+}
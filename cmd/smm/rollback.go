@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/api"
+	"github.com/ShawnEdgell/skaterxl-map-manager/installer"
+)
+
+// backupRecord is the JSON representation of a backed up map version.
+type backupRecord struct {
+	MapID       int       `json:"map_id"`
+	Name        string    `json:"name"`
+	ModfileID   int       `json:"modfile_id"`
+	Version     string    `json:"version"`
+	InstalledAt time.Time `json:"installed_at"`
+	BackedUpAt  time.Time `json:"backed_up_at"`
+	Files       int       `json:"files"`
+}
+
+func runRollback(args []string) int {
+	fs := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	list := fs.Bool("list", false, "List the backed up versions instead of restoring one")
+	version := fs.String("version", "", "Version to restore (defaults to the most recent backup)")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		return usageError("smm rollback [-list] [-version v] <id|name>")
+	}
+	query := fs.Arg(0)
+
+	if *list {
+		return listBackups(query)
+	}
+
+	rec, err := installer.RestoreBackup(query, *version)
+	if err != nil {
+		return out.failure("rollback", api.Map{Name: query}, err, "Failed to roll back %s: %v", query, err)
+	}
+	out.result(newInstallResult("rollback", api.Map{ID: rec.MapID, Name: rec.Name}, rec, nil))
+	out.infof("Restored %s version %s (%s)", rec.Name, rec.InstalledVersion(), rec.Location())
+	return exitOK
+}
+
+func listBackups(query string) int {
+	manifest, err := installer.LoadManifest()
+	if err != nil {
+		return out.errorf(err, "Error loading install manifest: %v", err)
+	}
+	rec := manifest.Find(query)
+	if rec == nil {
+		err := fmt.Errorf("%w: %s", installer.ErrNotInstalled, query)
+		return out.errorf(err, "%v", err)
+	}
+	backups, err := installer.ListBackups(rec.MapID)
+	if err != nil {
+		return out.errorf(err, "Error listing backups: %v", err)
+	}
+
+	if out.machine() {
+		for _, backup := range backups {
+			out.emit("backup", backupRecord{
+				MapID:       backup.MapID,
+				Name:        backup.Name,
+				ModfileID:   backup.ModfileID,
+				Version:     backup.Version,
+				InstalledAt: backup.InstalledAt,
+				BackedUpAt:  backup.BackedUpAt,
+				Files:       len(backup.Files),
+			})
+		}
+		return exitOK
+	}
+
+	if len(backups) == 0 {
+		out.infof("No backups of %s.", rec.Name)
+		return exitOK
+	}
+	out.infof("Installed: %s version %s", rec.Name, rec.Version)
+	w := tabwriter.NewWriter(out.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tFILE ID\tFILES\tBACKED UP")
+	for _, backup := range backups {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", backup.Version, backup.ModfileID, len(backup.Files), backup.BackedUpAt.Local().Format("2006-01-02 15:04"))
+	}
+	w.Flush()
+	return exitOK
+}
@@ -9,10 +9,28 @@ import (
 )
 
 const configFileName = "skaterxl_cli_config.json"
+const manifestFileName = "installed_maps.json"
+const profilesFileName = "profiles.json"
 
 // Config holds the application configuration.
 type Config struct {
-	SkaterXLMapsDir string `json:"skater_xl_maps_dir"`
+	SkaterXLMapsDir       string   `json:"skater_xl_maps_dir"`
+	APIBaseURL            string   `json:"api_base_url,omitempty"`            // Empty selects the default skatebit API
+	RequestTimeoutSeconds int      `json:"request_timeout_seconds,omitempty"` // Zero selects the default timeout
+	HeaderTimeoutSeconds  int      `json:"header_timeout_seconds,omitempty"`  // Wait for the server to answer; zero selects api.DefaultHeaderTimeout
+	IdleTimeoutSeconds    int      `json:"idle_timeout_seconds,omitempty"`    // Stalled download timeout; zero selects api.DefaultIdleTimeout
+	Offline               bool     `json:"offline,omitempty"`                 // Use the cached catalog without contacting the API
+	DownloadConcurrency   int      `json:"download_concurrency,omitempty"`    // Zero selects installer.DefaultConcurrency
+	DownloadDir           string   `json:"download_dir,omitempty"`            // Empty selects installer.DefaultDownloadDir
+	BackupVersions        int      `json:"backup_versions,omitempty"`         // Zero selects installer.DefaultBackupVersions, negative disables backups
+	BackupDir             string   `json:"backup_dir,omitempty"`              // Empty selects installer.DefaultBackupDir
+	DisabledDir           string   `json:"disabled_dir,omitempty"`            // Empty keeps disabled maps next to the maps directory
+	ExtractMaxMB          int      `json:"extract_max_mb,omitempty"`          // Extraction limits: zero selects installer.DefaultExtractLimits, negative disables the limit
+	ExtractMaxEntries     int      `json:"extract_max_entries,omitempty"`
+	ExtractMaxRatio       int      `json:"extract_max_ratio,omitempty"`
+	ExtractMaxDepth       int      `json:"extract_max_depth,omitempty"`
+	TagInclude            []string `json:"tag_include,omitempty"` // Tag filter of the map list: only maps with all of these tags
+	TagExclude            []string `json:"tag_exclude,omitempty"` // and none of these
 }
 
 // GetConfigPath returns the path to the configuration file.
@@ -24,6 +42,24 @@ func GetConfigPath() (string, error) {
 	return filepath.Join(configDir, "skaterxl-map-manager", configFileName), nil
 }
 
+// GetManifestPath returns the path to the install manifest, which lives next to the configuration file.
+func GetManifestPath() (string, error) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(configPath), manifestFileName), nil
+}
+
+// GetProfilesPath returns the path to the saved map profiles, which live next to the configuration file.
+func GetProfilesPath() (string, error) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(configPath), profilesFileName), nil
+}
+
 // LoadConfig loads the configuration from the file.
 func LoadConfig() (*Config, error) {
 	configPath, err := GetConfigPath()
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownKey is returned by Get and Set for keys that are not configuration settings.
+var ErrUnknownKey = errors.New("unknown config key")
+
+// Keys returns the names of the settings that can be read and written with Get and Set.
+func Keys() []string {
+	return []string{"skater_xl_maps_dir", "api_base_url", "request_timeout_seconds", "header_timeout_seconds", "idle_timeout_seconds", "offline", "download_concurrency", "download_dir", "backup_versions", "backup_dir", "disabled_dir", "extract_max_mb", "extract_max_entries", "extract_max_ratio", "extract_max_depth", "tag_include", "tag_exclude"}
+}
+
+// Get returns the value of the named setting as a string.
+func (c *Config) Get(key string) (string, error) {
+	switch key {
+	case "skater_xl_maps_dir":
+		return c.SkaterXLMapsDir, nil
+	case "api_base_url":
+		return c.APIBaseURL, nil
+	case "request_timeout_seconds":
+		return strconv.Itoa(c.RequestTimeoutSeconds), nil
+	case "header_timeout_seconds":
+		return strconv.Itoa(c.HeaderTimeoutSeconds), nil
+	case "idle_timeout_seconds":
+		return strconv.Itoa(c.IdleTimeoutSeconds), nil
+	case "offline":
+		return strconv.FormatBool(c.Offline), nil
+	case "download_concurrency":
+		return strconv.Itoa(c.DownloadConcurrency), nil
+	case "download_dir":
+		return c.DownloadDir, nil
+	case "backup_versions":
+		return strconv.Itoa(c.BackupVersions), nil
+	case "backup_dir":
+		return c.BackupDir, nil
+	case "disabled_dir":
+		return c.DisabledDir, nil
+	case "extract_max_mb":
+		return strconv.Itoa(c.ExtractMaxMB), nil
+	case "extract_max_entries":
+		return strconv.Itoa(c.ExtractMaxEntries), nil
+	case "extract_max_ratio":
+		return strconv.Itoa(c.ExtractMaxRatio), nil
+	case "extract_max_depth":
+		return strconv.Itoa(c.ExtractMaxDepth), nil
+	case "tag_include":
+		return strings.Join(c.TagInclude, ","), nil
+	case "tag_exclude":
+		return strings.Join(c.TagExclude, ","), nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, key)
+	}
+}
+
+// Set parses value and stores it in the named setting.
+func (c *Config) Set(key, value string) error {
+	switch key {
+	case "skater_xl_maps_dir":
+		c.SkaterXLMapsDir = value
+	case "api_base_url":
+		c.APIBaseURL = value
+	case "request_timeout_seconds", "header_timeout_seconds", "idle_timeout_seconds":
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			return fmt.Errorf("%s must be a non-negative number of seconds", key)
+		}
+		switch key {
+		case "request_timeout_seconds":
+			c.RequestTimeoutSeconds = seconds
+		case "header_timeout_seconds":
+			c.HeaderTimeoutSeconds = seconds
+		default:
+			c.IdleTimeoutSeconds = seconds
+		}
+	case "offline":
+		offline, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false", key)
+		}
+		c.Offline = offline
+	case "download_concurrency":
+		concurrency, err := strconv.Atoi(value)
+		if err != nil || concurrency < 0 {
+			return fmt.Errorf("%s must be a non-negative number", key)
+		}
+		c.DownloadConcurrency = concurrency
+	case "download_dir":
+		c.DownloadDir = value
+	case "backup_versions":
+		versions, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s must be a number (0 for the default, negative to disable backups)", key)
+		}
+		c.BackupVersions = versions
+	case "backup_dir":
+		c.BackupDir = value
+	case "disabled_dir":
+		c.DisabledDir = value
+	case "extract_max_mb", "extract_max_entries", "extract_max_ratio", "extract_max_depth":
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s must be a number (0 for the default, negative to disable the limit)", key)
+		}
+		switch key {
+		case "extract_max_mb":
+			c.ExtractMaxMB = limit
+		case "extract_max_entries":
+			c.ExtractMaxEntries = limit
+		case "extract_max_ratio":
+			c.ExtractMaxRatio = limit
+		default:
+			c.ExtractMaxDepth = limit
+		}
+	case "tag_include":
+		c.TagInclude = splitList(value)
+	case "tag_exclude":
+		c.TagExclude = splitList(value)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownKey, key)
+	}
+	return nil
+}
+
+// splitList parses a comma separated list, dropping empty items. An empty value yields an empty list.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,248 @@
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/api"
+)
+
+// Match kinds reported in Adoption.Match, strongest first.
+const (
+	MatchName     = "name"
+	MatchFilename = "file name"
+	MatchPartial  = "partial name and size"
+)
+
+// minPartialMatch is the shortest name that may match as part of a longer one.
+const minPartialMatch = 5
+
+// Adoption proposes that an untracked folder or file in the maps directory is a manual install of Map.
+type Adoption struct {
+	Map    api.Map
+	Path   string   // Folder, or loose file, in the maps directory
+	IsFile bool     // Path is a loose file rather than a folder
+	Files  []string // Relative to Path for folders, the file's own name for loose files
+	Size   int64
+	Match  string // MatchName, MatchFilename or MatchPartial
+}
+
+// ScanUntracked looks for folders and files in mapsDir that SMM does not track and matches them to catalog
+// maps by name, archive file name and size. It returns the proposed adoptions and the paths it could not match.
+func ScanUntracked(mapsDir string, catalog []api.Map, manifest *Manifest) ([]*Adoption, []string, error) {
+	entries, err := os.ReadDir(mapsDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read maps directory '%s': %w", mapsDir, err)
+	}
+
+	// The disabled area only holds maps SMM manages, should it be configured inside the maps directory.
+	tracked := map[string]bool{filepath.Clean(disabledDir(mapsDir)): true}
+	for _, rec := range manifest.Maps {
+		tracked[filepath.Clean(rec.Folder)] = true
+	}
+	var candidates []api.Map
+	for _, m := range catalog {
+		if manifest.Get(m.ID) == nil {
+			candidates = append(candidates, m)
+		}
+	}
+
+	var adoptions []*Adoption
+	var unmatched []string
+	claimed := map[int]bool{}
+	for _, entry := range entries {
+		path := filepath.Join(mapsDir, entry.Name())
+		if strings.HasPrefix(entry.Name(), ".") || isJunk(entry.Name()) || tracked[filepath.Clean(path)] {
+			continue
+		}
+
+		adoption, err := newAdoption(path, entry)
+		if err != nil {
+			Logger.Printf("Skipping '%s' in maps scan: %v", path, err)
+			continue
+		}
+		if adoption.Size == 0 {
+			continue
+		}
+		m, match := matchMap(entry.Name(), adoption.Size, candidates, claimed)
+		if match == "" {
+			unmatched = append(unmatched, path)
+			continue
+		}
+		adoption.Map, adoption.Match = m, match
+		claimed[m.ID] = true
+		adoptions = append(adoptions, adoption)
+	}
+	Logger.Printf("Maps scan of '%s': %d matched, %d unmatched.", mapsDir, len(adoptions), len(unmatched))
+	return adoptions, unmatched, nil
+}
+
+// newAdoption lists the files of an untracked maps directory entry.
+func newAdoption(path string, entry os.DirEntry) (*Adoption, error) {
+	adoption := &Adoption{Path: path}
+	if !entry.IsDir() {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		adoption.IsFile = true
+		adoption.Files = []string{entry.Name()}
+		adoption.Size = info.Size()
+		return adoption, nil
+	}
+
+	files, err := listFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	adoption.Files = files
+	adoption.Size = (&InstalledMap{Folder: path, Files: files}).DiskUsage()
+	return adoption, nil
+}
+
+// matchMap picks the catalog map best matching an entry name. Exact matches on the map name win over matches
+// on the archive file name; when several maps match equally, the one whose download size is closest wins.
+// Partial name matches are only accepted when the sizes are plausible.
+func matchMap(entryName string, size int64, candidates []api.Map, claimed map[int]bool) (api.Map, string) {
+	key := matchKey(strings.TrimSuffix(entryName, filepath.Ext(entryName)))
+	if key == "" {
+		return api.Map{}, ""
+	}
+
+	var best api.Map
+	bestMatch, bestRank, bestDistance := "", 0, math.Inf(1)
+	for _, m := range candidates {
+		if claimed[m.ID] {
+			continue
+		}
+		match, rank := "", 0
+		switch {
+		case key == matchKey(m.Name) || key == matchKey(m.NameID):
+			match, rank = MatchName, 3
+		case key == matchKey(strings.TrimSuffix(m.Modfile.Filename, filepath.Ext(m.Modfile.Filename))):
+			match, rank = MatchFilename, 2
+		case partialMatch(key, matchKey(m.Name)) && plausibleSize(size, int64(m.Modfile.Filesize)):
+			match, rank = MatchPartial, 1
+		default:
+			continue
+		}
+		distance := math.Abs(math.Log(float64(size+1) / float64(m.Modfile.Filesize+1)))
+		if rank > bestRank || (rank == bestRank && distance < bestDistance) {
+			best, bestMatch, bestRank, bestDistance = m, match, rank, distance
+		}
+	}
+	return best, bestMatch
+}
+
+// matchKey lowercases name and drops everything but letters and digits, so "Cool_Park v2" matches "cool park v2".
+func matchKey(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func partialMatch(a, b string) bool {
+	if len(a) < minPartialMatch || len(b) < minPartialMatch {
+		return false
+	}
+	return strings.Contains(a, b) || strings.Contains(b, a)
+}
+
+// plausibleSize reports whether an installed size could have come from an archive of the given size.
+// Maps are mostly compressed asset bundles, so the installed size is rarely far from the download size.
+func plausibleSize(installed, archive int64) bool {
+	if archive <= 0 {
+		return false
+	}
+	ratio := float64(installed) / float64(archive)
+	return ratio >= 0.5 && ratio <= 4
+}
+
+// Adopt records the given adoptions in the install manifest so SMM manages them from now on. Loose files are
+// first moved into a folder of their own named after the map, since SMM manages maps by folder. Adoptions
+// matched by name or archive file name whose size fits the catalog's current release are recorded as that
+// release; the others are recorded with an unknown version, which update checks skip. It returns the records
+// written; adoptions that fail are skipped and reported together in the error.
+func Adopt(mapsDir string, adoptions []*Adoption) ([]*InstalledMap, error) {
+	var recs []*InstalledMap
+	var failures []error
+	err := UpdateManifest(func(manifest *Manifest) error {
+		for _, adoption := range adoptions {
+			if manifest.Get(adoption.Map.ID) != nil {
+				Logger.Printf("Not adopting '%s': '%s' is already installed.", adoption.Path, adoption.Map.Name)
+				continue
+			}
+			folder, files := adoption.Path, adoption.Files
+			if adoption.IsFile {
+				var err error
+				if folder, err = moveIntoFolder(mapsDir, adoption); err != nil {
+					failures = append(failures, err)
+					continue
+				}
+			}
+			rec := &InstalledMap{
+				MapID:       adoption.Map.ID,
+				Name:        adoption.Map.Name,
+				InstalledAt: time.Now().UTC(),
+				Folder:      folder,
+				Files:       files,
+			}
+			if adoption.KnownRelease() {
+				rec.ModfileID, rec.Version = adoption.Map.Modfile.ID, adoption.Map.Modfile.Version
+			} else {
+				rec.VersionUnknown = true
+			}
+			manifest.Put(rec)
+			recs = append(recs, rec)
+			Logger.Printf("Adopted '%s' as '%s' version %s (matched by %s).", adoption.Path, adoption.Map.Name, rec.InstalledVersion(), adoption.Match)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recs, errors.Join(failures...)
+}
+
+// KnownRelease reports whether the adopted files are taken to be the catalog's current release of the map:
+// the match is by name or archive file name, and the size is plausible for that release's download.
+func (a *Adoption) KnownRelease() bool {
+	if a.Match != MatchName && a.Match != MatchFilename {
+		return false
+	}
+	return a.Map.Modfile.ID != 0 && plausibleSize(a.Size, int64(a.Map.Modfile.Filesize))
+}
+
+// Version returns the release the adoption will be recorded as, "unknown" when it is not known.
+func (a *Adoption) Version() string {
+	if !a.KnownRelease() {
+		return "unknown"
+	}
+	return a.Map.Modfile.Version
+}
+
+// moveIntoFolder moves a loose map file into a new folder named after its map and returns the folder.
+func moveIntoFolder(mapsDir string, adoption *Adoption) (string, error) {
+	folder := filepath.Join(mapsDir, sanitizeFilename(adoption.Map.Name))
+	if _, err := os.Stat(folder); err == nil {
+		return "", fmt.Errorf("cannot adopt '%s': '%s' already exists", adoption.Path, folder)
+	}
+	if err := os.Mkdir(folder, 0755); err != nil {
+		return "", fmt.Errorf("failed to create map folder '%s': %w", folder, err)
+	}
+	if err := os.Rename(adoption.Path, filepath.Join(folder, adoption.Files[0])); err != nil {
+		os.Remove(folder)
+		return "", fmt.Errorf("failed to move '%s' into '%s': %w", adoption.Path, folder, err)
+	}
+	return folder, nil
+}
@@ -0,0 +1,376 @@
+package installer
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nwaples/rardecode/v2"
+)
+
+// ErrUnsupportedArchive is returned for downloads that are not zip, rar or 7z archives.
+var ErrUnsupportedArchive = errors.New("unsupported archive format")
+
+// archiveFormat identifies an archive type.
+type archiveFormat string
+
+const (
+	formatZip      archiveFormat = "zip"
+	formatRar      archiveFormat = "rar"
+	formatSevenZip archiveFormat = "7z"
+)
+
+// archiveMagic lists the leading bytes of each supported format. Map archives are often misnamed,
+// so the format is never taken from the file extension.
+var archiveMagic = []struct {
+	format archiveFormat
+	magic  []byte
+}{
+	{formatZip, []byte("PK\x03\x04")},
+	{formatZip, []byte("PK\x05\x06")},   // Empty zip
+	{formatRar, []byte("Rar!\x1a\x07")}, // RAR 4 and RAR 5
+	{formatSevenZip, sevenZipSignature},
+}
+
+// archiveEntry describes one file or directory in an archive.
+type archiveEntry struct {
+	Name   string // Slash separated path inside the archive
+	IsDir  bool
+	Size   int64       // Uncompressed size, -1 if unknown
+	Packed int64       // Compressed size, zero if unknown
+	Mode   os.FileMode // Permission bits, zero if the archive does not record them
+}
+
+// archiveReader is implemented by each supported archive format.
+type archiveReader interface {
+	// Entries lists the archive contents without extracting them.
+	Entries() ([]archiveEntry, error)
+	// Walk calls fn for every entry in archive order. For files, r yields the uncompressed contents and is
+	// only valid until fn returns; for directories it is nil.
+	Walk(fn func(entry archiveEntry, r io.Reader) error) error
+	Close() error
+}
+
+// detectArchiveFormat reads the first bytes of path and returns its archive format.
+func detectArchiveFormat(path string) (archiveFormat, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	header := make([]byte, 8)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", fmt.Errorf("failed to read archive header: %w", err)
+	}
+	for _, m := range archiveMagic {
+		if bytes.HasPrefix(header[:n], m.magic) {
+			return m.format, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedArchive, filepath.Base(path))
+}
+
+// openArchive opens path with the reader matching its format. Readers that parse the archive index up front
+// reject indexes exceeding limits before allocating anything for them.
+func openArchive(path string, limits ExtractLimits) (archiveReader, error) {
+	format, err := detectArchiveFormat(path)
+	if err != nil {
+		return nil, err
+	}
+	Logger.Printf("Detected %s archive '%s'.", format, path)
+
+	switch format {
+	case formatZip:
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, err
+		}
+		return &zipArchive{r: r}, nil
+	case formatRar:
+		return &rarArchive{path: path}, nil
+	default:
+		return openSevenZip(path, limits)
+	}
+}
+
+// extractArchive extracts every entry of the archive at src below dest, reporting progress in uncompressed bytes.
+// Archives exceeding Limits are rejected with a *LimitError. Nothing extracted is left behind on failure.
+func extractArchive(src, dest string, progressCallback ProgressCallback) error {
+	limits := Limits
+	archive, err := openArchive(src, limits)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	entries, err := archive.Entries()
+	if err != nil {
+		return fmt.Errorf("failed to read archive contents: %w", err)
+	}
+	if err := limits.checkEntries(entries); err != nil {
+		return err
+	}
+	var totalSize int64
+	for _, entry := range entries {
+		if !entry.IsDir && entry.Size > 0 {
+			totalSize += entry.Size
+		}
+	}
+
+	_, statErr := os.Stat(dest)
+	createdDest := os.IsNotExist(statErr)
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+
+	var written []string
+	var extractedBytes, limitedBytes int64
+	var entryCount int
+	progress := &ProgressWriter{Callback: func(n int64) {
+		extractedBytes += n
+		if progressCallback != nil {
+			progressCallback(extractedBytes, totalSize)
+		}
+	}}
+
+	err = archive.Walk(func(entry archiveEntry, r io.Reader) error {
+		// Entries() and Walk() parse the archive separately, so enforce the limits again as it is read.
+		entryCount++
+		if limits.MaxEntries > 0 && entryCount > limits.MaxEntries {
+			return &LimitError{Limit: "entries", Actual: fmt.Sprintf("more than %d", limits.MaxEntries), Max: fmt.Sprint(limits.MaxEntries)}
+		}
+		if err := limits.checkDepth(entry); err != nil {
+			return err
+		}
+		path, err := entryPath(dest, entry.Name)
+		if err != nil {
+			return err
+		}
+		if entry.IsDir {
+			return os.MkdirAll(path, 0755)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+
+		mode := entry.Mode.Perm()
+		if mode == 0 {
+			mode = 0644
+		}
+		outFile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+		if err != nil {
+			return err
+		}
+		written = append(written, path)
+		limited := &limitReader{r: r, entry: entry, limits: limits, total: &limitedBytes}
+		_, err = io.Copy(outFile, io.TeeReader(limited, progress))
+		if closeErr := outFile.Close(); err == nil {
+			err = closeErr
+		}
+		var limitErr *LimitError
+		if errors.As(err, &limitErr) {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("failed to extract '%s': %w", entry.Name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		removeExtracted(dest, createdDest, written)
+		return err
+	}
+	if progress.written > 0 {
+		progress.Callback(progress.written)
+	}
+	return nil
+}
+
+// removeExtracted cleans up after a failed extraction: dest itself when extractArchive created it, otherwise the
+// files written to it.
+func removeExtracted(dest string, createdDest bool, written []string) {
+	if createdDest {
+		if err := os.RemoveAll(dest); err != nil {
+			Logger.Printf("Failed to remove partly extracted '%s': %v", dest, err)
+		}
+		return
+	}
+	for _, path := range written {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			Logger.Printf("Failed to remove partly extracted '%s': %v", path, err)
+		}
+	}
+}
+
+// entryPath resolves an archive entry name below dest, rejecting names that would escape it.
+func entryPath(dest, name string) (string, error) {
+	// Archives created on Windows sometimes use backslashes as separators.
+	name = strings.ReplaceAll(name, "\\", "/")
+	path := filepath.Join(dest, filepath.FromSlash(name))
+	if !strings.HasPrefix(path, filepath.Clean(dest)+string(os.PathSeparator)) {
+		return "", fmt.Errorf("illegal file path: %s", name)
+	}
+	return path, nil
+}
+
+// zipArchive reads zip files with archive/zip.
+type zipArchive struct {
+	r *zip.ReadCloser
+}
+
+func (a *zipArchive) Entries() ([]archiveEntry, error) {
+	entries := make([]archiveEntry, 0, len(a.r.File))
+	for _, f := range a.r.File {
+		entries = append(entries, zipEntry(f))
+	}
+	return entries, nil
+}
+
+func (a *zipArchive) Walk(fn func(entry archiveEntry, r io.Reader) error) error {
+	for _, f := range a.r.File {
+		entry := zipEntry(f)
+		if entry.IsDir {
+			if err := fn(entry, nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		rc, err := openZipFile(f)
+		if err != nil {
+			return err
+		}
+		err = fn(entry, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *zipArchive) Close() error {
+	return a.r.Close()
+}
+
+// openZipFile decompresses the data of f. Unlike f.Open, it does not stop at the uncompressed size recorded in
+// the headers, so that a file larger than its headers claim reaches the extraction limits as a *LimitError
+// instead of failing as a malformed archive. The CRC32 is still checked at the end.
+func openZipFile(f *zip.File) (io.ReadCloser, error) {
+	raw, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+	var rc io.ReadCloser
+	switch f.Method {
+	case zip.Store:
+		rc = io.NopCloser(raw)
+	case zip.Deflate:
+		rc = flate.NewReader(raw)
+	default:
+		return nil, fmt.Errorf("'%s': %w", f.Name, zip.ErrAlgorithm)
+	}
+	return &crcReader{ReadCloser: rc, hash: crc32.NewIEEE(), want: f.CRC32}, nil
+}
+
+// crcReader fails with zip.ErrChecksum at the end of a file whose CRC32 does not match want. A zero want
+// means the archive did not record one.
+type crcReader struct {
+	io.ReadCloser
+	hash hash.Hash32
+	want uint32
+}
+
+func (r *crcReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF && r.want != 0 && r.hash.Sum32() != r.want {
+		return n, zip.ErrChecksum
+	}
+	return n, err
+}
+
+func zipEntry(f *zip.File) archiveEntry {
+	return archiveEntry{
+		Name:   f.Name,
+		IsDir:  f.FileInfo().IsDir(),
+		Size:   int64(f.UncompressedSize64),
+		Packed: int64(f.CompressedSize64),
+		Mode:   f.Mode(),
+	}
+}
+
+// rarArchive reads RAR 4 and RAR 5 files with rardecode.
+type rarArchive struct {
+	path string
+}
+
+func (a *rarArchive) Entries() ([]archiveEntry, error) {
+	files, err := rardecode.List(a.path)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]archiveEntry, 0, len(files))
+	for _, f := range files {
+		entries = append(entries, rarEntry(&f.FileHeader))
+	}
+	return entries, nil
+}
+
+func (a *rarArchive) Walk(fn func(entry archiveEntry, r io.Reader) error) error {
+	r, err := rardecode.OpenReader(a.path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Encrypted {
+			return fmt.Errorf("'%s' is encrypted, password protected archives are not supported", header.Name)
+		}
+
+		entry := rarEntry(header)
+		var contents io.Reader = r
+		if entry.IsDir {
+			contents = nil
+		}
+		if err := fn(entry, contents); err != nil {
+			return err
+		}
+	}
+}
+
+func (a *rarArchive) Close() error {
+	return nil
+}
+
+func rarEntry(h *rardecode.FileHeader) archiveEntry {
+	entry := archiveEntry{
+		Name:   h.Name,
+		IsDir:  h.IsDir,
+		Size:   h.UnPackedSize,
+		Packed: h.PackedSize,
+		Mode:   h.Mode(),
+	}
+	if h.UnKnownSize {
+		entry.Size = -1
+	}
+	return entry
+}
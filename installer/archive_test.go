@@ -0,0 +1,209 @@
+package installer
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The archives in testdata are generated by testdata/genfixtures.py.
+
+var (
+	coolPark = strings.Repeat("UnityFS cool park ", 200)
+	preview  = strings.Repeat("preview ", 200)
+)
+
+// extractedTree returns the files below dir with their contents and the directories, as slash separated paths.
+func extractedTree(t *testing.T, dir string) (map[string]string, []string) {
+	t.Helper()
+	files := map[string]string{}
+	var dirs []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			dirs = append(dirs, rel)
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		files[rel] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files, dirs
+}
+
+func TestExtractArchiveFixtures(t *testing.T) {
+	tests := []struct {
+		fixture string
+		format  archiveFormat
+		files   map[string]string // Expected contents; see sizes for files too large to spell out
+		sizes   map[string]int
+		dirs    []string
+	}{
+		{
+			fixture: "lzma.7z",
+			format:  formatSevenZip,
+			files:   map[string]string{"Cool Park/cool park": coolPark, "Cool Park/preview.txt": preview},
+			dirs:    []string{"Cool Park"},
+		},
+		{
+			fixture: "lzma2.7z",
+			format:  formatSevenZip,
+			files:   map[string]string{"Cool Park/cool park": coolPark, "Cool Park/preview.txt": preview},
+			dirs:    []string{"Cool Park"},
+		},
+		{
+			// The 7z reader checks the CRC of every file, so intact output proves the BCJ conversion.
+			fixture: "bcj.7z",
+			format:  formatSevenZip,
+			sizes:   map[string]int{"Cool Park/plugin.dll": 16 * 1024},
+			dirs:    []string{"Cool Park"},
+		},
+		{
+			fixture: "encoded-header.7z",
+			format:  formatSevenZip,
+			files:   map[string]string{"Cool Park/cool park": coolPark, "Cool Park/preview.txt": preview},
+			dirs:    []string{"Cool Park"},
+		},
+		{
+			fixture: "empty.7z",
+			format:  formatSevenZip,
+			files:   map[string]string{"Cool Park/cool park": coolPark, "Cool Park/empty.txt": ""},
+			dirs:    []string{"Cool Park", "Cool Park/empty folder"},
+		},
+		{
+			// A 7z archive named .zip, as map archives often are.
+			fixture: "lzma.7z.zip",
+			format:  formatSevenZip,
+			files:   map[string]string{"Cool Park/cool park": coolPark, "Cool Park/preview.txt": preview},
+			dirs:    []string{"Cool Park"},
+		},
+		{
+			fixture: "stored.rar",
+			format:  formatRar,
+			files:   map[string]string{"Cool Park/cool park": coolPark, "Cool Park/preview.txt": preview},
+			dirs:    []string{"Cool Park"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			src := filepath.Join("testdata", tt.fixture)
+			format, err := detectArchiveFormat(src)
+			if err != nil {
+				t.Fatalf("detectArchiveFormat() error = %v", err)
+			}
+			if format != tt.format {
+				t.Errorf("detectArchiveFormat() = %s, want %s", format, tt.format)
+			}
+
+			dest := t.TempDir()
+			if err := extractArchive(src, dest, nil); err != nil {
+				t.Fatalf("extractArchive() error = %v", err)
+			}
+			files, dirs := extractedTree(t, dest)
+			if len(files) != len(tt.files)+len(tt.sizes) {
+				t.Errorf("extracted files %v, want %d files", keys(files), len(tt.files)+len(tt.sizes))
+			}
+			for name, want := range tt.files {
+				if got, ok := files[name]; !ok {
+					t.Errorf("%s was not extracted", name)
+				} else if got != want {
+					t.Errorf("%s has %d bytes of unexpected content", name, len(got))
+				}
+			}
+			for name, want := range tt.sizes {
+				if got := len(files[name]); got != want {
+					t.Errorf("%s has %d bytes, want %d", name, got, want)
+				}
+			}
+			if strings.Join(dirs, ",") != strings.Join(tt.dirs, ",") {
+				t.Errorf("extracted directories %v, want %v", dirs, tt.dirs)
+			}
+		})
+	}
+}
+
+func TestDetectArchiveFormat(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := writeZip(t, dir, []zipFile{{name: "Cool Park/cool park", data: []byte(coolPark)}})
+	textPath := filepath.Join(dir, "map.7z")
+	if err := os.WriteFile(textPath, []byte("not an archive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if format, err := detectArchiveFormat(zipPath); err != nil || format != formatZip {
+		t.Errorf("detectArchiveFormat(zip) = %s, %v, want %s", format, err, formatZip)
+	}
+	if _, err := detectArchiveFormat(textPath); !errors.Is(err, ErrUnsupportedArchive) {
+		t.Errorf("detectArchiveFormat(text) error = %v, want ErrUnsupportedArchive", err)
+	}
+}
+
+func TestExtractArchiveRejectsTraversal(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"7z":  filepath.Join("testdata", "traversal.7z"),
+		"zip": writeZip(t, dir, []zipFile{{name: "Cool Park/cool park", data: []byte(coolPark)}, {name: "../escaped.txt", data: []byte("outside")}}),
+	}
+	for name, src := range tests {
+		t.Run(name, func(t *testing.T) {
+			parent := t.TempDir()
+			dest := filepath.Join(parent, "extracted")
+			err := extractArchive(src, dest, nil)
+			if err == nil || !strings.Contains(err.Error(), "illegal file path") {
+				t.Fatalf("extractArchive() error = %v, want an illegal file path error", err)
+			}
+			if _, err := os.Stat(filepath.Join(parent, "escaped.txt")); !os.IsNotExist(err) {
+				t.Errorf("file escaped the extraction directory")
+			}
+			if files, _ := extractedTree(t, parent); len(files) != 0 {
+				t.Errorf("files left behind: %v", keys(files))
+			}
+		})
+	}
+}
+
+func TestSevenZipChecksum(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "lzma2.7z"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]int{
+		"packed data": szSignatureHeaderSize + 8,
+		"header":      len(data) - 8,
+	}
+	for name, offset := range tests {
+		t.Run(name, func(t *testing.T) {
+			corrupt := append([]byte(nil), data...)
+			corrupt[offset] ^= 0xFF
+			src := filepath.Join(t.TempDir(), "corrupt.7z")
+			if err := os.WriteFile(src, corrupt, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := extractArchive(src, filepath.Join(t.TempDir(), "extracted"), nil); err == nil {
+				t.Fatal("extractArchive() succeeded on a corrupt archive")
+			}
+		})
+	}
+}
+
+func keys(m map[string]string) []string {
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,231 @@
+package installer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DefaultBackupVersions is the number of replaced versions kept per map when no limit is configured.
+const DefaultBackupVersions = 3
+
+const backupRecordFileName = "backup.json"
+
+// BackupDir holds the versions of maps replaced by a reinstall, update or restore.
+// When empty, replaced versions are discarded.
+var BackupDir string
+
+// BackupVersions is the number of replaced versions kept per map. Zero or less disables backups.
+var BackupVersions = DefaultBackupVersions
+
+// ErrNoBackup is returned when a map has no backup to restore.
+var ErrNoBackup = errors.New("no backup available")
+
+// Backup is a replaced version of a map kept in BackupDir.
+type Backup struct {
+	InstalledMap
+	BackedUpAt time.Time `json:"backed_up_at"`
+	Path       string    `json:"-"` // Directory holding backup.json and the backed up files
+}
+
+// filesDir is the directory holding the backed up files, laid out as they were in the map folder.
+func (b *Backup) filesDir() string {
+	return filepath.Join(b.Path, "files")
+}
+
+// DefaultBackupDir returns the backup directory inside the user cache directory.
+func DefaultBackupDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "skaterxl-map-manager", "backups"), nil
+}
+
+func mapBackupDir(mapID int) string {
+	return filepath.Join(BackupDir, strconv.Itoa(mapID))
+}
+
+// ListBackups returns the backups of the given map, newest first.
+func ListBackups(mapID int) ([]*Backup, error) {
+	if BackupDir == "" {
+		return nil, nil
+	}
+	dir := mapBackupDir(mapID)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read backup directory '%s': %w", dir, err)
+	}
+
+	var backups []*Backup
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		backupPath := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(filepath.Join(backupPath, backupRecordFileName))
+		if err != nil {
+			// A backup without a record was interrupted while being written.
+			Logger.Printf("Ignoring incomplete backup '%s': %v", backupPath, err)
+			continue
+		}
+		var backup Backup
+		if err := json.Unmarshal(data, &backup); err != nil {
+			Logger.Printf("Ignoring corrupt backup '%s': %v", backupPath, err)
+			continue
+		}
+		backup.Path = backupPath
+		backups = append(backups, &backup)
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].BackedUpAt.After(backups[j].BackedUpAt) })
+	return backups, nil
+}
+
+// saveBackup copies the files recorded in rec from folder into a new backup, then drops the oldest
+// backups of the map beyond BackupVersions. Recorded files that no longer exist are skipped.
+func saveBackup(rec *InstalledMap, folder string) error {
+	if BackupDir == "" || BackupVersions <= 0 {
+		return nil
+	}
+
+	dir := mapBackupDir(rec.MapID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory '%s': %w", dir, err)
+	}
+	now := time.Now().UTC()
+	backupPath, err := os.MkdirTemp(dir, now.Format("20060102-150405")+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create backup directory in '%s': %w", dir, err)
+	}
+
+	backup := &Backup{InstalledMap: *rec, BackedUpAt: now, Path: backupPath}
+	backup.Files = nil
+	for _, rel := range rec.Files {
+		src := filepath.Join(folder, filepath.FromSlash(rel))
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		dest := filepath.Join(backup.filesDir(), filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			os.RemoveAll(backupPath)
+			return fmt.Errorf("failed to create directory for '%s': %w", rel, err)
+		}
+		if err := copyFile(src, dest); err != nil {
+			os.RemoveAll(backupPath)
+			return err
+		}
+		backup.Files = append(backup.Files, rel)
+	}
+
+	// The record is written last, so ListBackups never sees a partial backup.
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		os.RemoveAll(backupPath)
+		return fmt.Errorf("failed to marshal backup record: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(backupPath, backupRecordFileName), data, 0644); err != nil {
+		os.RemoveAll(backupPath)
+		return fmt.Errorf("failed to write backup record: %w", err)
+	}
+	Logger.Printf("Backed up '%s' version '%s' to '%s'.", rec.Name, rec.Version, backupPath)
+
+	return pruneBackups(rec.MapID)
+}
+
+// pruneBackups removes the oldest backups of the map beyond BackupVersions.
+func pruneBackups(mapID int) error {
+	backups, err := ListBackups(mapID)
+	if err != nil {
+		return err
+	}
+	for i := BackupVersions; i < len(backups); i++ {
+		Logger.Printf("Removing old backup '%s'.", backups[i].Path)
+		if err := os.RemoveAll(backups[i].Path); err != nil {
+			return fmt.Errorf("failed to remove old backup '%s': %w", backups[i].Path, err)
+		}
+	}
+	return nil
+}
+
+// RestoreBackup replaces the installed version of the map matching query (an ID, map name or folder name)
+// with one of its backups and returns the restored record. version selects the backup by version string;
+// empty selects the newest one. The version being replaced is backed up in turn, so a restore can be undone.
+func RestoreBackup(query string, version string) (*InstalledMap, error) {
+	manifest, err := LoadManifest()
+	if err != nil {
+		return nil, err
+	}
+	current := manifest.Find(query)
+	if current == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotInstalled, query)
+	}
+
+	backups, err := ListBackups(current.MapID)
+	if err != nil {
+		return nil, err
+	}
+	var backup *Backup
+	for _, candidate := range backups {
+		if version == "" || candidate.Version == version {
+			backup = candidate
+			break
+		}
+	}
+	if backup == nil {
+		if version != "" {
+			return nil, fmt.Errorf("%w: no backup of '%s' with version '%s'", ErrNoBackup, current.Name, version)
+		}
+		return nil, fmt.Errorf("%w: '%s' has no previous versions", ErrNoBackup, current.Name)
+	}
+	return restoreBackup(current, backup)
+}
+
+// restoreBackup replaces the installed version current with backup, backing up current in turn.
+func restoreBackup(current *InstalledMap, backup *Backup) (*InstalledMap, error) {
+	// A disabled map is restored where it is and stays disabled.
+	stage, err := newStaging(current.Location())
+	if err != nil {
+		return nil, err
+	}
+	defer stage.cleanup()
+
+	// Copy rather than move the backup, so it survives if the restore fails.
+	payload := filepath.Join(stage.dir, "restored")
+	if err := os.MkdirAll(payload, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create restore directory: %w", err)
+	}
+	for _, rel := range backup.Files {
+		dest := filepath.Join(payload, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory for '%s': %w", rel, err)
+		}
+		if err := copyFile(filepath.Join(backup.filesDir(), filepath.FromSlash(rel)), dest); err != nil {
+			return nil, err
+		}
+	}
+
+	rec := backup.InstalledMap
+	rec.Folder = current.Folder
+	rec.DisabledFolder = current.DisabledFolder
+	rec.Pinned = current.Pinned
+	rec.InstalledAt = time.Now().UTC()
+	if err := stage.commit(payload, &rec, current.Files); err != nil {
+		return nil, err
+	}
+
+	if err := os.RemoveAll(backup.Path); err != nil {
+		Logger.Printf("Failed to remove restored backup '%s': %v", backup.Path, err)
+	}
+	stage.backupPrevious(current)
+
+	Logger.Printf("Restored '%s' to version '%s'.", rec.Name, rec.Version)
+	return &rec, nil
+}
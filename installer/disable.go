@@ -0,0 +1,122 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// disabledDirSuffix names the default disabled area, a sibling of the maps directory, e.g. "Maps.smm-disabled".
+const disabledDirSuffix = ".smm-disabled"
+
+// DisabledDir holds the folders of disabled maps. When empty, each maps directory gets a disabled area next
+// to it. It must lie outside the maps directory, since Skater XL loads every map it finds there.
+var DisabledDir string
+
+// disabledDir returns the disabled area for maps installed in mapsDir.
+func disabledDir(mapsDir string) string {
+	if DisabledDir != "" {
+		return DisabledDir
+	}
+	mapsDir = filepath.Clean(mapsDir)
+	return filepath.Join(filepath.Dir(mapsDir), filepath.Base(mapsDir)+disabledDirSuffix)
+}
+
+// Enabled reports whether the map is in the maps directory, where the game loads it.
+func (r *InstalledMap) Enabled() bool {
+	return r.DisabledFolder == ""
+}
+
+// Location returns the folder currently holding the map's files: Folder while enabled, DisabledFolder while disabled.
+func (r *InstalledMap) Location() string {
+	if r.DisabledFolder != "" {
+		return r.DisabledFolder
+	}
+	return r.Folder
+}
+
+// SetEnabled moves the folder of the map matching query (an ID, map name or folder name) out of the maps
+// directory into the disabled area, or back again, and records the new state. Files the user added to the
+// folder move with it. Maps already in the requested state are returned unchanged. If the manifest cannot
+// be saved, the folder is moved back.
+func SetEnabled(query string, enabled bool) (*InstalledMap, error) {
+	var changed *InstalledMap
+	var movedFrom, movedTo string // Set once the folder has moved
+	err := UpdateManifest(func(manifest *Manifest) error {
+		rec := manifest.Find(query)
+		if rec == nil {
+			return fmt.Errorf("%w: %s", ErrNotInstalled, query)
+		}
+		changed = rec
+		if rec.Enabled() == enabled {
+			return nil
+		}
+
+		if enabled {
+			Logger.Printf("Enabling '%s': moving '%s' back to '%s'.", rec.Name, rec.DisabledFolder, rec.Folder)
+			if err := moveDir(rec.DisabledFolder, rec.Folder); err != nil {
+				return fmt.Errorf("failed to enable '%s': %w", rec.Name, err)
+			}
+			movedFrom, movedTo = rec.DisabledFolder, rec.Folder
+			// Drop the disabled area once its last map is enabled; this fails harmlessly while others remain.
+			os.Remove(filepath.Dir(rec.DisabledFolder))
+			rec.DisabledFolder = ""
+			return nil
+		}
+
+		mapsDir := filepath.Dir(rec.Folder)
+		if withinDir(filepath.ToSlash(disabledDir(mapsDir)), filepath.ToSlash(mapsDir)) {
+			return fmt.Errorf("cannot disable '%s': the disabled maps folder '%s' is inside the maps directory, where the game still loads them", rec.Name, disabledDir(mapsDir))
+		}
+		target := filepath.Join(disabledDir(mapsDir), filepath.Base(rec.Folder))
+		Logger.Printf("Disabling '%s': moving '%s' to '%s'.", rec.Name, rec.Folder, target)
+		if err := moveDir(rec.Folder, target); err != nil {
+			return fmt.Errorf("failed to disable '%s': %w", rec.Name, err)
+		}
+		movedFrom, movedTo = rec.Folder, target
+		rec.DisabledFolder = target
+		return nil
+	})
+	if err != nil && movedTo != "" {
+		if undoErr := moveDir(movedTo, movedFrom); undoErr != nil {
+			Logger.Printf("Moving '%s' back to '%s' failed: %v", movedTo, movedFrom, undoErr)
+			return nil, fmt.Errorf("failed to record the change in the manifest (%v) and to move the map back: %w", err, undoErr)
+		}
+		if !enabled {
+			os.Remove(filepath.Dir(movedTo))
+		}
+		Logger.Printf("Moved '%s' back to '%s' after failing to save the manifest.", movedTo, movedFrom)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// moveDir moves the folder src to dest, which must not exist yet. Folders on another drive are copied and
+// then removed, since they cannot be renamed there.
+func moveDir(src, dest string) error {
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("map folder '%s' is missing: %w", src, err)
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("'%s' already exists", dest)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create '%s': %w", filepath.Dir(dest), err)
+	}
+
+	err := os.Rename(src, dest)
+	if err == nil {
+		return nil
+	}
+	Logger.Printf("Cannot rename '%s' to '%s' (%v), copying instead.", src, dest, err)
+	if copyErr := copyTree(src, dest); copyErr != nil {
+		os.RemoveAll(dest)
+		return fmt.Errorf("failed to move '%s' to '%s': %w", src, dest, copyErr)
+	}
+	if err := os.RemoveAll(src); err != nil {
+		return fmt.Errorf("copied '%s' to '%s' but failed to remove the original: %w", src, dest, err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// errFreeSpaceUnsupported is returned by freeSpace on platforms where free space cannot be queried.
+var errFreeSpaceUnsupported = errors.New("free space cannot be determined on this platform")
+
+// DiskSpaceError reports that a filesystem does not have room for an install.
+type DiskSpaceError struct {
+	MapName   string
+	Path      string // Directory on the filesystem that is too full
+	Required  int64
+	Available int64
+}
+
+func (e *DiskSpaceError) Error() string {
+	return fmt.Sprintf("not enough disk space to install '%s' in '%s': %s needed, %s free",
+		e.MapName, e.Path, FormatSize(e.Required), FormatSize(e.Available))
+}
+
+// checkFreeSpace returns a *DiskSpaceError if the filesystem holding dir has less than required bytes free.
+// dir does not need to exist yet. If free space cannot be determined the check is skipped.
+func checkFreeSpace(mapName, dir string, required int64) error {
+	if required <= 0 {
+		return nil
+	}
+	existing := existingAncestor(dir)
+	available, err := freeSpace(existing)
+	if err != nil {
+		Logger.Printf("Skipping disk space check for '%s': %v", dir, err)
+		return nil
+	}
+	if int64(available) < required {
+		return &DiskSpaceError{MapName: mapName, Path: existing, Required: required, Available: int64(available)}
+	}
+	return nil
+}
+
+// existingAncestor returns dir or its nearest parent that exists.
+func existingAncestor(dir string) string {
+	dir = filepath.Clean(dir)
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// archiveUncompressedSize returns the total uncompressed size of the files in the archive at path.
+func archiveUncompressedSize(path string) (int64, error) {
+	archive, err := openArchive(path, Limits)
+	if err != nil {
+		return 0, err
+	}
+	defer archive.Close()
+
+	entries, err := archive.Entries()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read archive contents: %w", err)
+	}
+	var total int64
+	for _, entry := range entries {
+		if !entry.IsDir && entry.Size > 0 {
+			total += entry.Size
+		}
+	}
+	return total, nil
+}
+
+// DiskUsage returns the combined size of the files recorded for an installed map. Files that no longer
+// exist are not counted.
+func (r *InstalledMap) DiskUsage() int64 {
+	var total int64
+	for _, rel := range r.Files {
+		if info, err := os.Stat(filepath.Join(r.Location(), filepath.FromSlash(rel))); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+// FormatSize formats a byte count for display, e.g. "12.3 MB".
+func FormatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
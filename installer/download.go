@@ -0,0 +1,206 @@
+package installer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/api"
+)
+
+// DownloadDir keeps downloads, including partial ones, between runs so they can be resumed.
+// When empty, archives are downloaded into a temporary directory that is removed after each install.
+var DownloadDir string
+
+// DefaultDownloadDir returns the persistent download directory inside the user cache directory.
+func DefaultDownloadDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "skaterxl-map-manager", "downloads"), nil
+}
+
+// partialMeta is stored next to a partial download and holds the validator used for If-Range.
+type partialMeta struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+func (p partialMeta) validator() string {
+	// Weak ETags cannot be used with If-Range.
+	if p.ETag != "" && !strings.HasPrefix(p.ETag, "W/") {
+		return p.ETag
+	}
+	return p.LastModified
+}
+
+// downloadPath returns where the archive for m is kept in DownloadDir. Download URLs expire, so the
+// path is keyed on the modfile ID rather than the URL.
+func downloadPath(m api.Map) string {
+	return filepath.Join(DownloadDir, fmt.Sprintf("%d_%s", m.Modfile.ID, sanitizeFilename(filepath.Base(m.Modfile.Filename))))
+}
+
+// downloadedBytes returns how much of the download to path is already on disk, from a completed or partial download.
+func downloadedBytes(path string) int64 {
+	if info, err := os.Stat(path); err == nil {
+		return info.Size()
+	}
+	if info, err := os.Stat(path + ".part"); err == nil {
+		return info.Size()
+	}
+	return 0
+}
+
+// downloadFile downloads url to path. Data is written to path+".part" first; if a partial file from an
+// earlier attempt exists it is resumed with a Range request guarded by If-Range. Servers that ignore the
+// range, answer with another range or report a changed file cause a full download. expectedSize may be zero
+// if unknown.
+func downloadFile(ctx context.Context, client *api.Client, path string, url string, expectedSize int64, progressCallback ProgressCallback) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create download directory: %w", err)
+	}
+
+	if info, err := os.Stat(path); err == nil && expectedSize > 0 && info.Size() == expectedSize {
+		Logger.Printf("Reusing completed download '%s'.", path)
+		if progressCallback != nil {
+			progressCallback(expectedSize, expectedSize)
+		}
+		return nil
+	}
+
+	partPath := path + ".part"
+	metaPath := partPath + ".json"
+
+	var offset int64
+	meta := loadPartialMeta(metaPath)
+	if info, err := os.Stat(partPath); err == nil && meta.validator() != "" {
+		offset = info.Size()
+	}
+
+	header := http.Header{}
+	if offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		header.Set("If-Range", meta.validator())
+		Logger.Printf("Resuming download of '%s' at byte %d.", path, offset)
+	}
+
+	resp, err := client.Download(ctx, url, header)
+	var statusErr *api.StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		Logger.Printf("Server rejected range for '%s', starting over.", path)
+		os.Remove(partPath)
+		os.Remove(metaPath)
+		offset = 0
+		resp, err = client.Download(ctx, url, nil)
+	}
+	if err == nil && resp.StatusCode == http.StatusPartialContent && contentRangeStart(resp.Header.Get("Content-Range")) != offset {
+		// The body is some other part of the file and cannot be appended or saved as the whole file.
+		Logger.Printf("Server sent range %q instead of byte %d for '%s', starting over.", resp.Header.Get("Content-Range"), offset, path)
+		resp.Body.Close()
+		os.Remove(partPath)
+		os.Remove(metaPath)
+		offset = 0
+		resp, err = client.Download(ctx, url, nil)
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusPartialContent && offset == 0 {
+		return fmt.Errorf("server sent part of the file (%s) without being asked for a range", resp.Header.Get("Content-Range"))
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE
+	total := resp.ContentLength
+	if resp.StatusCode == http.StatusPartialContent {
+		flags |= os.O_APPEND
+		if total >= 0 {
+			total += offset
+		}
+	} else {
+		if offset > 0 {
+			Logger.Printf("Server does not support resuming '%s' or the file changed; downloading from the start.", path)
+		}
+		flags |= os.O_TRUNC
+		offset = 0
+	}
+	if total == -1 {
+		Logger.Println("Content-Length not available for progress tracking.")
+	}
+
+	savePartialMeta(metaPath, partialMeta{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")})
+
+	out, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return err
+	}
+
+	proxyReader := &ProgressReader{
+		Reader:       resp.Body,
+		Total:        total,
+		Current:      offset,
+		Callback:     progressCallback,
+		lastReported: offset,
+	}
+
+	_, err = io.Copy(out, proxyReader)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("download interrupted after %d bytes (it will resume on retry): %w", proxyReader.Current, err)
+	}
+
+	if err := os.Rename(partPath, path); err != nil {
+		return fmt.Errorf("failed to finalize download: %w", err)
+	}
+	os.Remove(metaPath)
+	return nil
+}
+
+// contentRangeStart parses the first byte position from a "bytes start-end/size" header, or returns -1.
+func contentRangeStart(contentRange string) int64 {
+	rest, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return -1
+	}
+	start, _, ok := strings.Cut(rest, "-")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+func loadPartialMeta(metaPath string) partialMeta {
+	var meta partialMeta
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		return meta
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		Logger.Printf("Ignoring corrupt partial download metadata '%s': %v", metaPath, err)
+	}
+	return meta
+}
+
+func savePartialMeta(metaPath string, meta partialMeta) {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(metaPath, data, 0644); err != nil {
+		Logger.Printf("Failed to write partial download metadata '%s': %v", metaPath, err)
+	}
+}
@@ -1,14 +1,14 @@
 package installer
 
 import (
-	"archive/zip"
+	"context"
 	"fmt"
 	"io"
 	"log"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/ShawnEdgell/skaterxl-map-manager/api"
 )
@@ -18,109 +18,166 @@ var Logger *log.Logger = log.Default()
 type ProgressMsg struct {
 	Total    int64
 	Current  int64
-	Type     string // "download" or "extract"
+	Type     string // "download", "verify" or "extract"
 }
 
-func InstallMap(mapToInstall api.Map, skaterXLMapsDir string, progressChan chan<- ProgressMsg) error {
+// InstallMap downloads mapToInstall with client, extracts it into skaterXLMapsDir and returns the recorded install.
+func InstallMap(ctx context.Context, client *api.Client, mapToInstall api.Map, skaterXLMapsDir string, progressChan chan<- ProgressMsg) (*InstalledMap, error) {
 	defer close(progressChan)
 	if mapToInstall.Modfile.Download.BinaryURL == "" {
-		return fmt.Errorf("no download URL found for map %s", mapToInstall.Name)
+		return nil, fmt.Errorf("no download URL found for map %s", mapToInstall.Name)
 	}
 
 	tempDir, err := os.MkdirTemp("", "skaterxl-map-download-*")
 	if err != nil {
-		return fmt.Errorf("failed to create temporary directory: %w", err)
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
 	}
 	defer os.RemoveAll(tempDir)
 
 	tempZipPath := filepath.Join(tempDir, mapToInstall.Modfile.Filename)
+	if DownloadDir != "" {
+		tempZipPath = downloadPath(mapToInstall)
+	}
+	// The extracted map is at least as large as its archive, which is all that is known before downloading.
+	downloadSize := int64(mapToInstall.Modfile.Filesize)
+	if err := checkFreeSpace(mapToInstall.Name, filepath.Dir(tempZipPath), downloadSize-downloadedBytes(tempZipPath)); err != nil {
+		return nil, err
+	}
+	if err := checkFreeSpace(mapToInstall.Name, skaterXLMapsDir, downloadSize); err != nil {
+		return nil, err
+	}
+
 	Logger.Printf("Downloading '%s' to '%s' from URL: %s", mapToInstall.Name, tempZipPath, mapToInstall.Modfile.Download.BinaryURL)
-	err = downloadFile(tempZipPath, mapToInstall.Modfile.Download.BinaryURL, func(current, total int64) {
+	err = downloadFile(ctx, client, tempZipPath, mapToInstall.Modfile.Download.BinaryURL, int64(mapToInstall.Modfile.Filesize), func(current, total int64) {
 		progressChan <- ProgressMsg{Type: "download", Current: current, Total: total}
 	})
 	if err != nil {
-		return fmt.Errorf("failed to download map: %w", err)
-	}
-
-	Logger.Printf("Extracting '%s'...")
-
-	mapDestinationDir := filepath.Join(skaterXLMapsDir, sanitizeFilename(mapToInstall.Name))
-
-	if _, err := os.Stat(mapDestinationDir); os.IsNotExist(err) {
-		err = os.MkdirAll(mapDestinationDir, 0755)
-		if err != nil {
-			return fmt.Errorf("failed to create map destination directory '%s': %w", mapDestinationDir, err)
-		}
-	} else if err != nil {
-		return fmt.Errorf("error checking map destination directory '%s': %w", mapDestinationDir, err)
+		return nil, fmt.Errorf("failed to download map: %w", err)
 	}
 
-	tempExtractDir := filepath.Join(tempDir, "extracted_zip")
-	if err := os.MkdirAll(tempExtractDir, 0755); err != nil {
-		return fmt.Errorf("failed to create temporary extraction directory: %w", err)
+	progressChan <- ProgressMsg{Type: "verify"}
+	err = verifyArchive(tempZipPath, mapToInstall.Name, int64(mapToInstall.Modfile.Filesize), mapToInstall.Modfile.Filehash.MD5)
+	if err != nil {
+		// Throw the bad file away so a retry downloads it again.
+		os.Remove(tempZipPath)
+		return nil, err
 	}
 
-	err = unzip(tempZipPath, tempExtractDir, func(current, total int64) {
-		progressChan <- ProgressMsg{Type: "extract", Current: current, Total: total}
-	})
+	// The real requirement is known now that the archive can be read, so check it rather than failing halfway.
+	unpackedSize, _ := archiveUncompressedSize(tempZipPath)
+	rec, err := installPayload(mapToInstall, skaterXLMapsDir, "", unpackedSize, extractTo(tempZipPath, mapToInstall.Name, progressChan))
 	if err != nil {
-		return fmt.Errorf("failed to extract map '%s' to temporary location: %w", mapToInstall.Name, err)
+		return nil, err
 	}
 
-	singleRootFolder, err := getSingleRootFolder(tempExtractDir)
-	if err == nil && singleRootFolder != "" {
-		Logger.Printf("Detected single root folder '%s' in zip. Moving contents to '%s'.", singleRootFolder, mapDestinationDir)
-		sourcePath := filepath.Join(tempExtractDir, singleRootFolder)
-		err = moveDirContents(sourcePath, mapDestinationDir)
-		if err != nil {
-			return fmt.Errorf("failed to move contents from single root folder: %w", err)
-		}
-	} else {
-		Logger.Printf("No single root folder detected or error: %v. Moving all extracted contents to '%s'.", err, mapDestinationDir)
-		err = moveDirContents(tempExtractDir, mapDestinationDir)
-		if err != nil {
-			return fmt.Errorf("failed to move extracted contents: %w", err)
+	if DownloadDir != "" {
+		if err := os.Remove(tempZipPath); err != nil {
+			Logger.Printf("Failed to remove downloaded archive '%s': %v", tempZipPath, err)
 		}
 	}
 
-	Logger.Printf("Successfully installed '%s' to '%s'!", mapToInstall.Name, mapDestinationDir)
-	return nil
+	Logger.Printf("Successfully installed '%s' to '%s'!", mapToInstall.Name, rec.Folder)
+	return rec, nil
 }
 
-type ProgressCallback func(current, total int64)
-
-func downloadFile(filepath string, url string, progressCallback ProgressCallback) error {
-	out, err := os.Create(filepath)
+// installPayload runs the install steps shared by every source: unpack fills a staging directory next to the
+// destination, the map payload is located in it and swapped into place, and the install is recorded with the
+// given source. requiredSize is the space the unpacked files need, zero if unknown. Everything up to the swap
+// happens in staging, so a failure at any point leaves an existing install untouched.
+func installPayload(m api.Map, mapsDir, source string, requiredSize int64, unpack func(dest string) error) (*InstalledMap, error) {
+	manifest, err := LoadManifest()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	mapDestinationDir := filepath.Join(mapsDir, sanitizeFilename(m.Name))
+	location, disabledFolder, pinned := mapDestinationDir, "", false
+	previous := manifest.Get(m.ID)
+	if previous != nil {
+		// Update in place, even if the map has been renamed since it was installed. Disabled maps stay disabled
+		// and pinned maps stay pinned, now to the release installed explicitly.
+		mapDestinationDir = previous.Folder
+		location, disabledFolder, pinned = previous.Location(), previous.DisabledFolder, previous.Pinned
+	} else if owner := manifest.FolderOwner(mapDestinationDir, m.ID); owner != nil {
+		// Checked again under the manifest lock before the swap; this only avoids a pointless download.
+		return nil, folderTakenError(m.Name, mapDestinationDir, owner)
 	}
-	defer out.Close()
 
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
+	if err := checkFreeSpace(m.Name, filepath.Dir(location), requiredSize); err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad status: %s", resp.Status)
+	stage, err := newStaging(location)
+	if err != nil {
+		return nil, err
 	}
+	defer stage.cleanup()
 
-	contentLength := resp.ContentLength
-	if contentLength == -1 {
-		Logger.Println("Content-Length not available for progress tracking.")
+	if err := unpack(stage.extractDir()); err != nil {
+		return nil, err
 	}
 
-	proxyReader := &ProgressReader{
-		Reader: resp.Body,
-		Total:  contentLength,
-		Callback: progressCallback,
+	layout, err := analyzeLayout(stage.extractDir())
+	if err != nil {
+		return nil, err
 	}
+	Logger.Printf("Archive layout for '%s': %s.", m.Name, layout)
+	sourcePath := filepath.Join(stage.extractDir(), filepath.FromSlash(layout.Root))
+
+	installedFiles, err := listFiles(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(installedFiles) == 0 {
+		return nil, fmt.Errorf("archive for map '%s' contains no files", m.Name)
+	}
+
+	rec := &InstalledMap{
+		MapID:          m.ID,
+		Name:           m.Name,
+		ModfileID:      m.Modfile.ID,
+		Version:        m.Modfile.Version,
+		InstalledAt:    time.Now().UTC(),
+		Folder:         mapDestinationDir,
+		Files:          installedFiles,
+		Layout:         layout.String(),
+		Source:         source,
+		DisabledFolder: disabledFolder,
+		Pinned:         pinned,
+	}
+	// Without a record of the previous install, anything the new version does not ship is treated as the user's.
+	managed := installedFiles
+	if previous != nil {
+		managed = previous.Files
+	}
+	if err := stage.commit(sourcePath, rec, managed); err != nil {
+		return nil, err
+	}
+	stage.backupPrevious(previous)
+	return rec, nil
+}
+
+// folderTakenError reports that the folder a map would be installed in belongs to another map.
+func folderTakenError(name, folder string, owner *InstalledMap) error {
+	return fmt.Errorf("cannot install '%s': its folder '%s' belongs to '%s'", name, folder, owner.Name)
+}
 
-	_, err = io.Copy(out, proxyReader)
-	return err
+// extractTo returns an unpack function for installPayload that extracts the archive at path, reporting progress.
+func extractTo(path, mapName string, progressChan chan<- ProgressMsg) func(dest string) error {
+	return func(dest string) error {
+		Logger.Printf("Extracting '%s' to '%s'...", path, dest)
+		progressChan <- ProgressMsg{Type: "extract"}
+		err := extractArchive(path, dest, func(current, total int64) {
+			progressChan <- ProgressMsg{Type: "extract", Current: current, Total: total}
+		})
+		if err != nil {
+			return fmt.Errorf("failed to extract map '%s' to staging directory: %w", mapName, err)
+		}
+		return nil
+	}
 }
 
+type ProgressCallback func(current, total int64)
+
 type ProgressReader struct {
 	Reader   io.Reader
 	Total    int64
@@ -144,79 +201,6 @@ func (pr *ProgressReader) Read(p []byte) (n int, err error) {
 	return
 }
 
-func unzip(src, dest string, progressCallback ProgressCallback) error {
-    r, err := zip.OpenReader(src)
-    if err != nil {
-        return err
-    }
-    defer func() {
-        if err := r.Close(); err != nil {
-            panic(err)
-        }
-    }()
-
-    os.MkdirAll(dest, 0755)
-
-    var totalSize int64
-    for _, f := range r.File {
-        if !f.FileInfo().IsDir() {
-            totalSize += int64(f.UncompressedSize64)
-        }
-    }
-
-    var extractedBytes int64
-    extractAndWriteFile := func(f *zip.File) error {
-        rc, err := f.Open()
-        if err != nil {
-            return err
-        }
-        defer func() {
-            if err := rc.Close(); err != nil {
-                panic(err)
-            }
-        }()
-
-        path := filepath.Join(dest, f.Name)
-        if !strings.HasPrefix(path, filepath.Clean(dest) + string(os.PathSeparator)) {
-            return fmt.Errorf("illegal file path: %s", path)
-        }
-
-        if f.FileInfo().IsDir() {
-            os.MkdirAll(path, f.Mode())
-        } else {
-            os.MkdirAll(filepath.Dir(path), f.Mode())
-            outFile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-            if err != nil {
-                return err
-            }
-            defer func() {
-                if err := outFile.Close(); err != nil {
-                    panic(err)
-                }
-            }()
-
-            _, err = io.Copy(outFile, io.TeeReader(rc, &ProgressWriter{Callback: func(n int64) {
-                extractedBytes += n
-                if progressCallback != nil {
-                    progressCallback(extractedBytes, totalSize)
-                }
-            }}))
-            if err != nil {
-                return err
-            }
-        }
-        return nil
-    }
-
-    for _, f := range r.File {
-        err := extractAndWriteFile(f)
-        if err != nil {
-            return err
-        }
-    }
-    return nil
-}
-
 type ProgressWriter struct {
     Callback func(n int64)
     written int64
@@ -232,25 +216,6 @@ func (pw *ProgressWriter) Write(p []byte) (n int, err error) {
     return n, nil
 }
 
-func getZipRootFolder(zipFilePath string) (string, error) {
-	r, err := zip.OpenReader(zipFilePath)
-	if err != nil {
-		return "", err
-	}
-	defer r.Close()
-
-	if len(r.File) == 0 {
-		return "", fmt.Errorf("zip file is empty")
-	}
-
-	firstPath := r.File[0].Name
-	parts := strings.Split(firstPath, string(os.PathSeparator))
-	if len(parts) > 0 && parts[0] != "" {
-		return parts[0], nil
-	}
-	return "", fmt.Errorf("could not determine root folder from zip")
-}
-
 func sanitizeFilename(name string) string {
     invalidChars := []string{"/", "\\", ":", "*", "?", "\"", "<", ">", "|"}
     for _, char := range invalidChars {
@@ -259,53 +224,6 @@ func sanitizeFilename(name string) string {
     return name
 }
 
-func getSingleRootFolder(extractedPath string) (string, error) {
-	entries, err := os.ReadDir(extractedPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to read extracted directory: %w", err)
-	}
-
-	var rootFolders []string
-	for _, entry := range entries {
-		if entry.IsDir() {
-			rootFolders = append(rootFolders, entry.Name())
-		} else {
-			return "", fmt.Errorf("files found directly in extracted root")
-		}
-	}
-
-	if len(rootFolders) == 1 {
-		return rootFolders[0], nil
-	}
-	return "", fmt.Errorf("no single root folder found")
-}
-
-func moveDirContents(src, dest string) error {
-	entries, err := os.ReadDir(src)
-	if err != nil {
-		return fmt.Errorf("failed to read source directory '%s': %w", src, err)
-	}
-
-	for _, entry := range entries {
-		srcPath := filepath.Join(src, entry.Name())
-		destPath := filepath.Join(dest, entry.Name())
-
-		if entry.IsDir() {
-			err := copyDir(srcPath, destPath)
-			if err != nil {
-				return fmt.Errorf("failed to copy directory '%s' to '%s': %w", srcPath, destPath, err)
-			}
-		} else {
-			err := copyFile(srcPath, destPath)
-			if err != nil {
-				return fmt.Errorf("failed to copy file '%s' to '%s': %w", srcPath, destPath, err)
-			}
-		}
-	}
-
-	return os.RemoveAll(src)
-}
-
 func copyFile(src, dest string) error {
 	sourceFile, err := os.Open(src)
 	if err != nil {
@@ -331,38 +249,3 @@ func copyFile(src, dest string) error {
 
 	return os.Chmod(dest, sourceInfo.Mode())
 }
-
-func copyDir(src, dest string) error {
-	sourceInfo, err := os.Stat(src)
-	if err != nil {
-		return fmt.Errorf("failed to get source directory info '%s': %w", src, err)
-	}
-
-	if err := os.MkdirAll(dest, sourceInfo.Mode()); err != nil {
-		return fmt.Errorf("failed to create destination directory '%s': %w", dest, err)
-	}
-
-	dirents, err := os.ReadDir(src)
-	if err != nil {
-		return fmt.Errorf("failed to read source directory '%s': %w", src, err)
-	}
-
-	for _, dirent := range dirents {
-		srcPath := filepath.Join(src, dirent.Name())
-		destPath := filepath.Join(dest, dirent.Name())
-
-		if dirent.IsDir() {
-			err := copyDir(srcPath, destPath)
-			if err != nil {
-				return err
-			}
-		} else {
-			err := copyFile(srcPath, destPath)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	return nil
-}
@@ -0,0 +1,199 @@
+package installer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// bundleMagic lists the signatures of Unity asset bundles, which hold the map itself.
+var bundleMagic = [][]byte{
+	[]byte("UnityFS\x00"),
+	[]byte("UnityWeb\x00"),
+	[]byte("UnityRaw\x00"),
+	[]byte("UnityArchive"),
+}
+
+// junkNames are files and folders added by archivers and file browsers that never belong in a map.
+var junkNames = map[string]bool{
+	"__macosx":    true,
+	".ds_store":   true,
+	"thumbs.db":   true,
+	"desktop.ini": true,
+}
+
+// Layout describes where the map payload sits inside an extracted archive.
+type Layout struct {
+	Root    string   // Payload directory, slash separated and relative to the extraction directory; empty for the top level
+	Bundles []string // Asset bundles found below Root
+	Junk    []string // Junk entries removed from the payload
+	Ignored []string // Files outside Root that are not installed
+}
+
+// String summarises the layout decision for logs and install results.
+func (l *Layout) String() string {
+	var parts []string
+	if l.Root == "" {
+		parts = append(parts, "installed archive top level")
+	} else {
+		parts = append(parts, fmt.Sprintf("installed folder '%s'", l.Root))
+	}
+	switch len(l.Bundles) {
+	case 0:
+		parts = append(parts, "no asset bundle recognised")
+	case 1:
+		parts = append(parts, "1 asset bundle")
+	default:
+		parts = append(parts, fmt.Sprintf("%d asset bundles", len(l.Bundles)))
+	}
+	if len(l.Ignored) > 0 {
+		parts = append(parts, fmt.Sprintf("skipped %d loose %s", len(l.Ignored), plural(len(l.Ignored), "file", "files")))
+	}
+	if len(l.Junk) > 0 {
+		parts = append(parts, fmt.Sprintf("removed %d junk %s", len(l.Junk), plural(len(l.Junk), "entry", "entries")))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// isJunk reports whether an archive entry name is archiver or file browser clutter.
+func isJunk(name string) bool {
+	return junkNames[strings.ToLower(name)] || strings.HasPrefix(name, "._")
+}
+
+// isAssetBundle reports whether the file at path starts with a Unity asset bundle signature.
+func isAssetBundle(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	header := make([]byte, 16)
+	n, _ := io.ReadFull(f, header)
+	for _, magic := range bundleMagic {
+		if bytes.HasPrefix(header[:n], magic) {
+			return true
+		}
+	}
+	return false
+}
+
+// analyzeLayout finds the map payload in an extracted archive. The payload is the deepest folder holding
+// every asset bundle, together with the companion files next to them; readmes and other files outside it
+// are skipped. When no bundle is recognised, folders that only wrap a single folder are unwrapped instead.
+// Junk entries are removed from extractDir. Archives without any other file are rejected.
+func analyzeLayout(extractDir string) (*Layout, error) {
+	layout := &Layout{}
+	var files []string
+	err := filepath.WalkDir(extractDir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == extractDir {
+			return nil
+		}
+		rel, err := filepath.Rel(extractDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if isJunk(d.Name()) {
+			layout.Junk = append(layout.Junk, rel)
+			if err := os.RemoveAll(p); err != nil {
+				return fmt.Errorf("failed to remove '%s': %w", rel, err)
+			}
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		files = append(files, rel)
+		if isAssetBundle(p) {
+			layout.Bundles = append(layout.Bundles, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze archive layout: %w", err)
+	}
+	if len(files) == 0 {
+		if len(layout.Junk) > 0 {
+			return nil, fmt.Errorf("archive contains no map files, only %d junk %s", len(layout.Junk), plural(len(layout.Junk), "entry", "entries"))
+		}
+		return nil, fmt.Errorf("archive contains no files")
+	}
+
+	if len(layout.Bundles) > 0 {
+		layout.Root = commonDir(layout.Bundles)
+	} else {
+		layout.Root, err = unwrapSingleFolders(extractDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, file := range files {
+		if !withinDir(file, layout.Root) {
+			layout.Ignored = append(layout.Ignored, file)
+		}
+	}
+	for i, bundle := range layout.Bundles {
+		layout.Bundles[i] = strings.TrimPrefix(strings.TrimPrefix(bundle, layout.Root), "/")
+	}
+	sort.Strings(layout.Ignored)
+	return layout, nil
+}
+
+// commonDir returns the deepest directory containing every path in paths, or "" for the top level.
+func commonDir(paths []string) string {
+	common := strings.Split(path.Dir(paths[0]), "/")
+	for _, p := range paths[1:] {
+		parts := strings.Split(path.Dir(p), "/")
+		n := 0
+		for n < len(common) && n < len(parts) && common[n] == parts[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	dir := strings.Join(common, "/")
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// unwrapSingleFolders descends from root through folders whose only entry is another folder, and returns
+// the first level holding files or several folders, relative to root.
+func unwrapSingleFolders(root string) (string, error) {
+	rel := ""
+	for {
+		entries, err := os.ReadDir(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil {
+			return "", fmt.Errorf("failed to read extracted directory: %w", err)
+		}
+		if len(entries) != 1 || !entries[0].IsDir() {
+			return rel, nil
+		}
+		rel = path.Join(rel, entries[0].Name())
+	}
+}
+
+// withinDir reports whether the slash separated path p lies inside dir, where "" is the top level.
+func withinDir(p, dir string) bool {
+	return dir == "" || strings.HasPrefix(p, dir+"/")
+}
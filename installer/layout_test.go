@@ -0,0 +1,132 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// bundle is the start of a Unity asset bundle, enough for isAssetBundle.
+const bundle = "UnityFS\x00\x00\x00\x00\x08"
+
+// writeTree creates the slash separated files in dir.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAnalyzeLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		root    string
+		summary string
+		wantErr bool
+	}{
+		{
+			name: "macOS archive",
+			files: map[string]string{
+				"__MACOSX/Cool Park/._cool park": "resource fork",
+				"Cool Park/cool park":            bundle,
+				"Cool Park/preview.png":          "png",
+			},
+			root:    "Cool Park",
+			summary: "installed folder 'Cool Park', 1 asset bundle, removed 1 junk entry",
+		},
+		{
+			name: "readme next to the map folder",
+			files: map[string]string{
+				"README.txt":            "thanks for downloading",
+				"Cool Park/cool park":   bundle,
+				"Cool Park/cool park.d": "companion",
+			},
+			root:    "Cool Park",
+			summary: "installed folder 'Cool Park', 1 asset bundle, skipped 1 loose file",
+		},
+		{
+			name: "map nested two levels deep",
+			files: map[string]string{
+				"Cool Park v2/Cool Park/cool park":   bundle,
+				"Cool Park v2/Cool Park/preview.jpg": "jpg",
+				"Cool Park v2/changelog.txt":         "v2",
+			},
+			root:    "Cool Park v2/Cool Park",
+			summary: "installed folder 'Cool Park v2/Cool Park', 1 asset bundle, skipped 1 loose file",
+		},
+		{
+			name: "loose files at the root",
+			files: map[string]string{
+				"cool park":   bundle,
+				"preview.png": "png",
+				".DS_Store":   "finder",
+			},
+			root:    "",
+			summary: "installed archive top level, 1 asset bundle, removed 1 junk entry",
+		},
+		{
+			name: "bundles in sibling folders",
+			files: map[string]string{
+				"Park Pack/Cool Park/cool park": bundle,
+				"Park Pack/DIY Spot/diy spot":   bundle,
+				"Park Pack/readme.txt":          "two parks",
+			},
+			root:    "Park Pack",
+			summary: "installed folder 'Park Pack', 2 asset bundles",
+		},
+		{
+			name: "no recognised bundle",
+			files: map[string]string{
+				"Wrapper/Cool Park/cool park.dat": "not a bundle",
+				"Wrapper/Cool Park/preview.png":   "png",
+			},
+			root:    "Wrapper/Cool Park",
+			summary: "installed folder 'Wrapper/Cool Park', no asset bundle recognised",
+		},
+		{
+			name: "only junk",
+			files: map[string]string{
+				"__MACOSX/._cool park": "resource fork",
+				".DS_Store":            "finder",
+				"Thumbs.db":            "explorer",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTree(t, dir, tt.files)
+
+			layout, err := analyzeLayout(dir)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("analyzeLayout() = %q, want an error", layout)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("analyzeLayout() error = %v", err)
+			}
+			if layout.Root != tt.root {
+				t.Errorf("Root = %q, want %q", layout.Root, tt.root)
+			}
+			if got := layout.String(); got != tt.summary {
+				t.Errorf("String() = %q, want %q", got, tt.summary)
+			}
+			for _, junk := range layout.Junk {
+				if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(junk))); !os.IsNotExist(err) {
+					t.Errorf("junk entry %q was not removed", junk)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package installer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ExtractLimits bounds the resources an archive may use when it is extracted. Zero disables a limit.
+type ExtractLimits struct {
+	MaxTotalBytes int64   // Total uncompressed size of all files
+	MaxEntries    int     // Number of files and directories
+	MaxRatio      float64 // Uncompressed to compressed size of a single file
+	MaxDepth      int     // Directory levels of any entry
+}
+
+// DefaultExtractLimits are generous enough for the largest maps on the catalog.
+var DefaultExtractLimits = ExtractLimits{
+	MaxTotalBytes: 16 << 30,
+	MaxEntries:    20000,
+	MaxRatio:      200,
+	MaxDepth:      16,
+}
+
+// Limits is applied to every archive extracted by InstallMap.
+var Limits = DefaultExtractLimits
+
+// ratioMinSize is the size below which the compression ratio is not checked. Small text files routinely
+// compress far better than any real map payload, and cannot do harm.
+const ratioMinSize = 1 << 20
+
+// LimitError reports that an archive exceeds one of the ExtractLimits.
+type LimitError struct {
+	Limit  string // "total size", "entries", "compression ratio", "depth" or "recorded size"
+	Entry  string // The offending entry, empty for limits on the whole archive
+	Actual string
+	Max    string
+}
+
+func (e *LimitError) Error() string {
+	if e.Entry == "" {
+		return fmt.Sprintf("archive exceeds the %s limit: %s (maximum %s)", e.Limit, e.Actual, e.Max)
+	}
+	return fmt.Sprintf("archive entry '%s' exceeds the %s limit: %s (maximum %s)", e.Entry, e.Limit, e.Actual, e.Max)
+}
+
+// checkEntries validates the archive contents as listed in its headers, before anything is written.
+func (l ExtractLimits) checkEntries(entries []archiveEntry) error {
+	if l.MaxEntries > 0 && len(entries) > l.MaxEntries {
+		return &LimitError{Limit: "entries", Actual: fmt.Sprint(len(entries)), Max: fmt.Sprint(l.MaxEntries)}
+	}
+
+	var total int64
+	for _, entry := range entries {
+		if err := l.checkDepth(entry); err != nil {
+			return err
+		}
+		if entry.IsDir || entry.Size < 0 {
+			continue
+		}
+		if err := l.checkRatio(entry, entry.Size); err != nil {
+			return err
+		}
+		total += entry.Size
+		if l.MaxTotalBytes > 0 && total > l.MaxTotalBytes {
+			return l.totalError(fmt.Sprintf("%d bytes", total))
+		}
+	}
+	return nil
+}
+
+func (l ExtractLimits) checkDepth(entry archiveEntry) error {
+	if depth := entryDepth(entry.Name); l.MaxDepth > 0 && depth > l.MaxDepth {
+		return &LimitError{Limit: "depth", Entry: entry.Name, Actual: fmt.Sprintf("%d levels", depth), Max: fmt.Sprintf("%d levels", l.MaxDepth)}
+	}
+	return nil
+}
+
+func (l ExtractLimits) checkRatio(entry archiveEntry, size int64) error {
+	if l.MaxRatio <= 0 || entry.Packed <= 0 || size < ratioMinSize {
+		return nil
+	}
+	if ratio := float64(size) / float64(entry.Packed); ratio > l.MaxRatio {
+		return &LimitError{Limit: "compression ratio", Entry: entry.Name, Actual: fmt.Sprintf("%.0f:1", ratio), Max: fmt.Sprintf("%.0f:1", l.MaxRatio)}
+	}
+	return nil
+}
+
+func (l ExtractLimits) totalError(actual string) error {
+	return &LimitError{Limit: "total size", Actual: actual, Max: fmt.Sprintf("%d bytes", l.MaxTotalBytes)}
+}
+
+// entryDepth returns the number of path components in an archive entry name.
+func entryDepth(name string) int {
+	name = strings.Trim(strings.ReplaceAll(name, "\\", "/"), "/")
+	if name == "" {
+		return 0
+	}
+	return strings.Count(name, "/") + 1
+}
+
+// limitReader enforces the limits on the bytes actually produced while extracting an entry, since the sizes
+// in archive headers can lie.
+type limitReader struct {
+	r      io.Reader
+	entry  archiveEntry
+	limits ExtractLimits
+	total  *int64 // Bytes extracted from the whole archive so far
+	n      int64  // Bytes extracted from this entry
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	*l.total += int64(n)
+
+	if l.entry.Size >= 0 && l.n > l.entry.Size {
+		// Headers understating the size are how archives slip past checkEntries.
+		return n, &LimitError{Limit: "recorded size", Entry: l.entry.Name, Actual: fmt.Sprintf("more than %d bytes", l.entry.Size), Max: fmt.Sprintf("%d bytes", l.entry.Size)}
+	}
+	if l.limits.MaxTotalBytes > 0 && *l.total > l.limits.MaxTotalBytes {
+		return n, l.limits.totalError(fmt.Sprintf("more than %d bytes", l.limits.MaxTotalBytes))
+	}
+	if ratioErr := l.limits.checkRatio(l.entry, l.n); ratioErr != nil {
+		return n, ratioErr
+	}
+	return n, err
+}
@@ -0,0 +1,205 @@
+package installer
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"errors"
+	"hash/crc32"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// zipFile is a file to put in a crafted zip. A non-zero claimedSize replaces the real uncompressed size in the
+// headers.
+type zipFile struct {
+	name        string
+	data        []byte
+	claimedSize uint64
+}
+
+// writeZip builds a zip archive of files in dir and returns its path.
+func writeZip(t *testing.T, dir string, files []zipFile) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		var compressed bytes.Buffer
+		fw, err := flate.NewWriter(&compressed, flate.BestCompression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(f.data)
+		fw.Close()
+		header := &zip.FileHeader{
+			Name:               f.name,
+			Method:             zip.Deflate,
+			CRC32:              crc32.ChecksumIEEE(f.data),
+			CompressedSize64:   uint64(compressed.Len()),
+			UncompressedSize64: uint64(len(f.data)),
+		}
+		if f.claimedSize != 0 {
+			header.UncompressedSize64 = f.claimedSize
+		}
+		w, err := zw.CreateRaw(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(compressed.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "map.zip")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// countFiles returns the number of files below dir, zero if it does not exist.
+func countFiles(t *testing.T, dir string) int {
+	t.Helper()
+	count := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestExtractLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits ExtractLimits
+		files  []zipFile
+		limit  string // Expected LimitError.Limit, empty if the archive is within the limits
+	}{
+		{
+			name:   "within limits",
+			limits: DefaultExtractLimits,
+			files:  []zipFile{{name: "Park/park.assetbundle", data: bytes.Repeat([]byte("map data "), 100)}},
+		},
+		{
+			name:   "total size",
+			limits: ExtractLimits{MaxTotalBytes: 1000},
+			files: []zipFile{
+				{name: "Park/a.assetbundle", data: bytes.Repeat([]byte("a"), 600)},
+				{name: "Park/b.assetbundle", data: bytes.Repeat([]byte("b"), 600)},
+			},
+			limit: "total size",
+		},
+		{
+			name:   "entries",
+			limits: ExtractLimits{MaxEntries: 3},
+			files: []zipFile{
+				{name: "Park/1.txt", data: []byte("1")},
+				{name: "Park/2.txt", data: []byte("2")},
+				{name: "Park/3.txt", data: []byte("3")},
+				{name: "Park/4.txt", data: []byte("4")},
+			},
+			limit: "entries",
+		},
+		{
+			name:   "depth",
+			limits: ExtractLimits{MaxDepth: 3},
+			files:  []zipFile{{name: "a/b/c/d/park.assetbundle", data: []byte("deep")}},
+			limit:  "depth",
+		},
+		{
+			name:   "compression ratio",
+			limits: DefaultExtractLimits,
+			files:  []zipFile{{name: "Park/zeros.bin", data: make([]byte, 4<<20)}},
+			limit:  "compression ratio",
+		},
+		{
+			// The headers claim a small file, which passes every check on the headers, so only the bytes
+			// actually produced by the extraction reveal it.
+			name:   "understated size",
+			limits: DefaultExtractLimits,
+			files: []zipFile{
+				{name: "Park/readme.txt", data: []byte("hello")},
+				{name: "Park/zeros.bin", data: make([]byte, 4<<20), claimedSize: 1000},
+			},
+			limit: "recorded size",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(limits ExtractLimits) { Limits = limits }(Limits)
+			Limits = tt.limits
+
+			dir := t.TempDir()
+			src := writeZip(t, dir, tt.files)
+			dest := filepath.Join(dir, "extracted")
+			err := extractArchive(src, dest, nil)
+
+			if tt.limit == "" {
+				if err != nil {
+					t.Fatalf("extractArchive() error = %v, want nil", err)
+				}
+				if got := countFiles(t, dest); got != len(tt.files) {
+					t.Errorf("extracted %d files, want %d", got, len(tt.files))
+				}
+				return
+			}
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("extractArchive() error = %v, want a *LimitError", err)
+			}
+			if limitErr.Limit != tt.limit {
+				t.Errorf("LimitError.Limit = %q, want %q (%v)", limitErr.Limit, tt.limit, err)
+			}
+			if got := countFiles(t, dest); got != 0 {
+				t.Errorf("%d files left behind in dest", got)
+			}
+		})
+	}
+}
+
+func TestExtractLimitsKeepExistingFiles(t *testing.T) {
+	defer func(limits ExtractLimits) { Limits = limits }(Limits)
+	Limits = DefaultExtractLimits
+
+	dir := t.TempDir()
+	src := writeZip(t, dir, []zipFile{
+		{name: "Park/readme.txt", data: []byte("hello")},
+		{name: "Park/zeros.bin", data: make([]byte, 4<<20), claimedSize: 1000},
+	})
+	dest := filepath.Join(dir, "extracted")
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		t.Fatal(err)
+	}
+	kept := filepath.Join(dest, "keep.txt")
+	if err := os.WriteFile(kept, []byte("mine"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := extractArchive(src, dest, nil)
+	if !errors.As(err, new(*LimitError)) {
+		t.Fatalf("extractArchive() error = %v, want a *LimitError", err)
+	}
+	if got := countFiles(t, dest); got != 1 {
+		t.Errorf("%d files in dest, want only the existing one", got)
+	}
+	if data, err := os.ReadFile(kept); err != nil || !strings.Contains(string(data), "mine") {
+		t.Errorf("existing file was not kept: %q, %v", data, err)
+	}
+}
@@ -0,0 +1,111 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/api"
+)
+
+// SourceLocal marks installs made from a local archive or folder rather than the catalog.
+const SourceLocal = "local"
+
+// localIDMu guards lastLocalID, so maps queued together get distinct IDs before any of them is recorded.
+var (
+	localIDMu   sync.Mutex
+	lastLocalID int
+)
+
+// LocalMap returns the entry used to install and track the archive or folder at path. Local maps have negative
+// IDs, which never clash with the catalog. Installing a local map under the name of an earlier local install
+// replaces it. An empty name is derived from the file or folder name.
+func LocalMap(path, name string) (api.Map, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return api.Map{}, fmt.Errorf("cannot install '%s': %w", path, err)
+	}
+	base := filepath.Base(filepath.Clean(path))
+	if name == "" {
+		name = base
+		if !info.IsDir() {
+			name = strings.TrimSuffix(base, filepath.Ext(base))
+		}
+	}
+
+	manifest, err := LoadManifest()
+	if err != nil {
+		return api.Map{}, err
+	}
+	m := api.Map{Name: name}
+	m.Modfile.Filename = base
+	if !info.IsDir() {
+		m.Modfile.Filesize = int(info.Size())
+	}
+
+	localIDMu.Lock()
+	defer localIDMu.Unlock()
+	for _, rec := range manifest.Maps {
+		if rec.Source == SourceLocal && strings.EqualFold(rec.Name, name) {
+			m.ID = rec.MapID
+			return m, nil
+		}
+		lastLocalID = min(lastLocalID, rec.MapID)
+	}
+	lastLocalID--
+	m.ID = lastLocalID
+	return m, nil
+}
+
+// InstallLocal installs the archive or folder at path as m, which comes from LocalMap, through the same
+// extraction, layout detection and tracking as catalog installs.
+func InstallLocal(m api.Map, path, skaterXLMapsDir string, progressChan chan<- ProgressMsg) (*InstalledMap, error) {
+	defer close(progressChan)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot install '%s': %w", path, err)
+	}
+
+	var rec *InstalledMap
+	if info.IsDir() {
+		files, listErr := listFiles(path)
+		if listErr != nil {
+			return nil, listErr
+		}
+		size := (&InstalledMap{Folder: path, Files: files}).DiskUsage()
+		rec, err = installPayload(m, skaterXLMapsDir, SourceLocal, size, func(dest string) error {
+			Logger.Printf("Copying '%s' to '%s'...", path, dest)
+			progressChan <- ProgressMsg{Type: "extract"}
+			return copyTree(path, dest)
+		})
+	} else {
+		unpackedSize, _ := archiveUncompressedSize(path)
+		rec, err = installPayload(m, skaterXLMapsDir, SourceLocal, unpackedSize, extractTo(path, m.Name, progressChan))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	Logger.Printf("Successfully installed local map '%s' from '%s' to '%s'!", m.Name, path, rec.Folder)
+	return rec, nil
+}
+
+// copyTree copies the files below src into dest, creating directories as needed.
+func copyTree(src, dest string) error {
+	files, err := listFiles(src)
+	if err != nil {
+		return err
+	}
+	for _, rel := range files {
+		target := filepath.Join(dest, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for '%s': %w", rel, err)
+		}
+		if err := copyFile(filepath.Join(src, filepath.FromSlash(rel)), target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/config"
+)
+
+const manifestVersion = 1
+
+// manifestMu serializes load-modify-save cycles on the manifest file.
+var manifestMu sync.Mutex
+
+// InstalledMap records a single map installed by SMM.
+type InstalledMap struct {
+	MapID          int       `json:"map_id"`
+	Name           string    `json:"name"`
+	ModfileID      int       `json:"modfile_id"`
+	Version        string    `json:"version"`
+	InstalledAt    time.Time `json:"installed_at"`
+	Folder         string    `json:"folder"`
+	Files          []string  `json:"files"`                     // Relative to Folder, slash separated
+	Layout         string    `json:"layout,omitempty"`          // Where the payload was found in the archive
+	Source         string    `json:"source,omitempty"`          // Empty for the catalog, SourceLocal for local files
+	DisabledFolder string    `json:"disabled_folder,omitempty"` // Where the map is kept while disabled, empty while enabled
+	Pinned         bool      `json:"pinned,omitempty"`          // Kept at the installed release: update checks skip it
+	VersionUnknown bool      `json:"version_unknown,omitempty"` // Adopted without knowing the release: update checks skip it
+}
+
+// Manifest is the on-disk database of maps installed by SMM.
+type Manifest struct {
+	Version int             `json:"version"`
+	Maps    []*InstalledMap `json:"maps"`
+}
+
+// LoadManifest reads the install manifest. A missing file yields an empty manifest.
+func LoadManifest() (*Manifest, error) {
+	manifestPath, err := config.GetManifestPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Manifest{Version: manifestVersion}, nil
+		}
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// SaveManifest writes the manifest atomically.
+func SaveManifest(manifest *Manifest) error {
+	manifestPath, err := config.GetManifestPath()
+	if err != nil {
+		return err
+	}
+
+	manifest.Version = manifestVersion
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := writeFileAtomic(manifestPath, data); err != nil {
+		return fmt.Errorf("failed to save manifest: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces the file at path with data by writing a temporary file next to it and renaming it
+// into place, so readers never see a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create directory '%s': %w", dir, err)
+	}
+
+	base := filepath.Base(path)
+	tmpFile, err := os.CreateTemp(dir, "."+strings.TrimSuffix(base, filepath.Ext(base))+"-*"+filepath.Ext(base))
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace '%s': %w", path, err)
+	}
+	return nil
+}
+
+// UpdateManifest loads the manifest, applies fn and saves the result while holding the manifest lock.
+func UpdateManifest(fn func(*Manifest) error) error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	manifest, err := LoadManifest()
+	if err != nil {
+		return err
+	}
+	if err := fn(manifest); err != nil {
+		return err
+	}
+	return SaveManifest(manifest)
+}
+
+// Get returns the record for the given map ID, or nil if it is not installed.
+func (m *Manifest) Get(mapID int) *InstalledMap {
+	for _, rec := range m.Maps {
+		if rec.MapID == mapID {
+			return rec
+		}
+	}
+	return nil
+}
+
+// Put adds or replaces the record for rec.MapID.
+func (m *Manifest) Put(rec *InstalledMap) {
+	for i, existing := range m.Maps {
+		if existing.MapID == rec.MapID {
+			m.Maps[i] = rec
+			return
+		}
+	}
+	m.Maps = append(m.Maps, rec)
+}
+
+// FolderOwner returns the record of another map than mapID installed in folder, or nil if there is none.
+func (m *Manifest) FolderOwner(folder string, mapID int) *InstalledMap {
+	for _, rec := range m.Maps {
+		if rec.MapID != mapID && filepath.Clean(rec.Folder) == filepath.Clean(folder) {
+			return rec
+		}
+	}
+	return nil
+}
+
+// Remove deletes the record for the given map ID and reports whether one was found.
+func (m *Manifest) Remove(mapID int) bool {
+	for i, rec := range m.Maps {
+		if rec.MapID == mapID {
+			m.Maps = append(m.Maps[:i], m.Maps[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Find looks up a record by numeric map ID, or by case-insensitive map name or folder name.
+func (m *Manifest) Find(query string) *InstalledMap {
+	if id, err := strconv.Atoi(query); err == nil {
+		if rec := m.Get(id); rec != nil {
+			return rec
+		}
+	}
+	for _, rec := range m.Maps {
+		if strings.EqualFold(rec.Name, query) || strings.EqualFold(filepath.Base(rec.Folder), query) {
+			return rec
+		}
+	}
+	return nil
+}
+
+// listFiles returns every regular file under root as a slash-separated path relative to root.
+func listFiles(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files in '%s': %w", root, err)
+	}
+	return files, nil
+}
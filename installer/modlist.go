@@ -0,0 +1,224 @@
+package installer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/api"
+)
+
+// ModlistVersion is the modlist format written by this version of SMM. It is bumped whenever a field is
+// renamed, removed or changes meaning; older modlists remain readable.
+const ModlistVersion = 1
+
+// Modlist file formats.
+const (
+	ModlistJSON = "json"
+	ModlistTOML = "toml"
+)
+
+// ErrModlistVersion is returned for modlists written by a newer SMM.
+var ErrModlistVersion = errors.New("unsupported modlist version")
+
+// Modlist is a shareable list of catalog maps, pinned to the exact files installed when it was exported.
+type Modlist struct {
+	Version    int            `json:"version" toml:"version"`
+	ExportedAt time.Time      `json:"exported_at" toml:"exported_at"`
+	Maps       []ModlistEntry `json:"maps" toml:"maps"`
+}
+
+// ModlistEntry pins one map of a modlist to a release.
+type ModlistEntry struct {
+	MapID     int    `json:"map_id" toml:"map_id"`
+	Name      string `json:"name" toml:"name"`
+	ModfileID int    `json:"modfile_id" toml:"modfile_id"`
+	Version   string `json:"version,omitempty" toml:"version,omitempty"`
+	Enabled   bool   `json:"enabled" toml:"enabled"`
+}
+
+// NewModlist lists the installed catalog maps in manifest. Maps installed from local files are left out,
+// since others cannot get them from the catalog; the number left out is returned with the list.
+func NewModlist(manifest *Manifest) (*Modlist, int) {
+	list := &Modlist{Version: ModlistVersion, ExportedAt: time.Now().UTC().Truncate(time.Second), Maps: []ModlistEntry{}}
+	skipped := 0
+	for _, rec := range manifest.Maps {
+		if rec.Source == SourceLocal {
+			skipped++
+			continue
+		}
+		list.Maps = append(list.Maps, ModlistEntry{
+			MapID:     rec.MapID,
+			Name:      rec.Name,
+			ModfileID: rec.ModfileID,
+			Version:   rec.Version,
+			Enabled:   rec.Enabled(),
+		})
+	}
+	sort.Slice(list.Maps, func(i, j int) bool { return strings.ToLower(list.Maps[i].Name) < strings.ToLower(list.Maps[j].Name) })
+	return list, skipped
+}
+
+// ModlistFormat picks the modlist format from a file name: TOML for .toml files, JSON otherwise.
+func ModlistFormat(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		return ModlistTOML
+	}
+	return ModlistJSON
+}
+
+// Encode writes the modlist to w in the given format.
+func (l *Modlist) Encode(w io.Writer, format string) error {
+	switch format {
+	case ModlistJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(l)
+	case ModlistTOML:
+		return toml.NewEncoder(w).Encode(l)
+	default:
+		return fmt.Errorf("unknown modlist format %q (expected json or toml)", format)
+	}
+}
+
+// DecodeModlist parses a modlist in the given format. An empty format detects JSON by its opening brace.
+func DecodeModlist(data []byte, format string) (*Modlist, error) {
+	if format == "" {
+		format = ModlistTOML
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+			format = ModlistJSON
+		}
+	}
+
+	var list Modlist
+	var err error
+	switch format {
+	case ModlistJSON:
+		err = json.Unmarshal(data, &list)
+	case ModlistTOML:
+		err = toml.Unmarshal(data, &list)
+	default:
+		return nil, fmt.Errorf("unknown modlist format %q (expected json or toml)", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s modlist: %w", strings.ToUpper(format), err)
+	}
+	if list.Version < 1 || list.Version > ModlistVersion {
+		return nil, fmt.Errorf("%w: %d (this SMM reads versions 1 to %d)", ErrModlistVersion, list.Version, ModlistVersion)
+	}
+	return &list, nil
+}
+
+// Import actions, reported in ImportStep.Action.
+const (
+	ImportInstall     = "install"     // Not installed: install the catalog release
+	ImportUpdate      = "update"      // Installed, but the pinned release is the catalog's newer one
+	ImportRestore     = "restore"     // Installed, and the pinned release is in the backups
+	ImportSkip        = "skip"        // Already installed at the pinned release, or the closest available one
+	ImportUnavailable = "unavailable" // Not in the catalog, so it cannot be installed
+)
+
+// ImportStep is what importing a modlist does for one of its maps.
+type ImportStep struct {
+	Entry  ModlistEntry
+	Map    api.Map // Catalog entry; zero for unavailable maps
+	Action string
+	Note   string // Why the pinned release could not be matched exactly, if it could not
+}
+
+// PlanImport decides how to reproduce each map of the modlist. The catalog only offers the latest release of
+// a map, so a pinned older release is restored from the backups when it is there and otherwise replaced by
+// the closest one available, with a note saying so.
+func PlanImport(list *Modlist, catalog []api.Map, manifest *Manifest) []*ImportStep {
+	byID := make(map[int]api.Map, len(catalog))
+	for _, m := range catalog {
+		byID[m.ID] = m
+	}
+
+	steps := make([]*ImportStep, 0, len(list.Maps))
+	for _, entry := range list.Maps {
+		step := &ImportStep{Entry: entry}
+		steps = append(steps, step)
+		rec := manifest.Get(entry.MapID)
+		m, inCatalog := byID[entry.MapID]
+		if inCatalog {
+			step.Map = m
+		}
+		pinnedLatest := !inCatalog || entry.ModfileID == 0 || entry.ModfileID == m.Modfile.ID
+
+		switch {
+		case rec != nil && rec.ModfileID == entry.ModfileID && entry.ModfileID != 0:
+			step.Action = ImportSkip
+		case rec != nil && backupOf(entry) != nil:
+			step.Action = ImportRestore
+		case !inCatalog:
+			step.Action = ImportUnavailable
+			step.Note = "no longer in the catalog"
+			if rec != nil {
+				step.Action = ImportSkip
+				step.Note = fmt.Sprintf("no longer in the catalog, keeping installed version %s", rec.InstalledVersion())
+			}
+		case rec == nil:
+			step.Action = ImportInstall
+			if !pinnedLatest {
+				step.Note = fmt.Sprintf("version %s is no longer offered, installing %s", entry.Version, m.Modfile.Version)
+			}
+		case rec.VersionUnknown:
+			// Adopted maps may already be the wanted release; reinstalling them on every import would not tell.
+			step.Action = ImportSkip
+			step.Note = "installed version unknown, keeping it (reinstall the map to match the modlist)"
+		case rec.ModfileID == m.Modfile.ID:
+			// Entries without a modfile ID ask for the latest release, which is already installed.
+			step.Action = ImportSkip
+		case pinnedLatest:
+			step.Action = ImportUpdate
+		default:
+			step.Action = ImportSkip
+			step.Note = fmt.Sprintf("version %s is no longer offered, keeping installed version %s", entry.Version, rec.InstalledVersion())
+		}
+	}
+	return steps
+}
+
+// backupOf returns the backup holding the release pinned by entry, if there is one.
+func backupOf(entry ModlistEntry) *Backup {
+	if entry.ModfileID == 0 {
+		return nil
+	}
+	backups, err := ListBackups(entry.MapID)
+	if err != nil {
+		Logger.Printf("Cannot look for a backup of '%s': %v", entry.Name, err)
+		return nil
+	}
+	for _, backup := range backups {
+		if backup.ModfileID == entry.ModfileID {
+			return backup
+		}
+	}
+	return nil
+}
+
+// RestorePinned restores the release pinned by entry from the backups of the installed map.
+func RestorePinned(entry ModlistEntry) (*InstalledMap, error) {
+	manifest, err := LoadManifest()
+	if err != nil {
+		return nil, err
+	}
+	current := manifest.Get(entry.MapID)
+	if current == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotInstalled, entry.Name)
+	}
+	backup := backupOf(entry)
+	if backup == nil {
+		return nil, fmt.Errorf("%w: no backup of '%s' with file %d", ErrNoBackup, entry.Name, entry.ModfileID)
+	}
+	return restoreBackup(current, backup)
+}
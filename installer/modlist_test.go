@@ -0,0 +1,81 @@
+package installer
+
+import (
+	"testing"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/api"
+)
+
+func TestPlanImport(t *testing.T) {
+	defer func(dir string) { BackupDir = dir }(BackupDir)
+	BackupDir = ""
+
+	coolPark := api.Map{ID: 1, Name: "Cool Park"}
+	coolPark.Modfile.ID, coolPark.Modfile.Version = 12, "1.2"
+	catalog := []api.Map{coolPark}
+
+	tests := []struct {
+		name      string
+		entry     ModlistEntry
+		installed *InstalledMap
+		action    string
+	}{
+		{
+			name:      "latest wanted, latest installed",
+			entry:     ModlistEntry{MapID: 1, Name: "Cool Park"},
+			installed: &InstalledMap{MapID: 1, ModfileID: 12, Version: "1.2"},
+			action:    ImportSkip,
+		},
+		{
+			name:      "latest wanted, older installed",
+			entry:     ModlistEntry{MapID: 1, Name: "Cool Park"},
+			installed: &InstalledMap{MapID: 1, ModfileID: 11, Version: "1.1"},
+			action:    ImportUpdate,
+		},
+		{
+			name:   "latest wanted, not installed",
+			entry:  ModlistEntry{MapID: 1, Name: "Cool Park"},
+			action: ImportInstall,
+		},
+		{
+			name:      "latest wanted, installed version unknown",
+			entry:     ModlistEntry{MapID: 1, Name: "Cool Park"},
+			installed: &InstalledMap{MapID: 1, VersionUnknown: true},
+			action:    ImportSkip,
+		},
+		{
+			name:      "current release pinned and installed",
+			entry:     ModlistEntry{MapID: 1, Name: "Cool Park", ModfileID: 12, Version: "1.2"},
+			installed: &InstalledMap{MapID: 1, ModfileID: 12, Version: "1.2"},
+			action:    ImportSkip,
+		},
+		{
+			name:      "older release pinned without a backup",
+			entry:     ModlistEntry{MapID: 1, Name: "Cool Park", ModfileID: 11, Version: "1.1"},
+			installed: &InstalledMap{MapID: 1, ModfileID: 12, Version: "1.2"},
+			action:    ImportSkip,
+		},
+		{
+			name:   "not in the catalog",
+			entry:  ModlistEntry{MapID: 2, Name: "DIY Spot"},
+			action: ImportUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := &Manifest{}
+			if tt.installed != nil {
+				manifest.Put(tt.installed)
+			}
+			list := &Modlist{Version: ModlistVersion, Maps: []ModlistEntry{tt.entry}}
+			steps := PlanImport(list, catalog, manifest)
+			if len(steps) != 1 {
+				t.Fatalf("PlanImport() returned %d steps, want 1", len(steps))
+			}
+			if steps[0].Action != tt.action {
+				t.Errorf("Action = %q (%s), want %q", steps[0].Action, steps[0].Note, tt.action)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package installer
+
+import "fmt"
+
+// SetPinned pins the map matching query (an ID, map name or folder name) to its installed release, so that
+// update checks skip it, or unpins it again. Maps already in the requested state are returned unchanged.
+func SetPinned(query string, pinned bool) (*InstalledMap, error) {
+	var changed *InstalledMap
+	err := UpdateManifest(func(manifest *Manifest) error {
+		rec := manifest.Find(query)
+		if rec == nil {
+			return fmt.Errorf("%w: %s", ErrNotInstalled, query)
+		}
+		changed = rec
+		if rec.Pinned == pinned {
+			return nil
+		}
+		if pinned {
+			Logger.Printf("Pinning '%s' to version %s (file %d).", rec.Name, rec.Version, rec.ModfileID)
+		} else {
+			Logger.Printf("Unpinning '%s'.", rec.Name)
+		}
+		rec.Pinned = pinned
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
@@ -0,0 +1,229 @@
+package installer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/config"
+)
+
+// ErrNoProfile is returned when no profile has the requested name.
+var ErrNoProfile = errors.New("profile not found")
+
+// ErrProfileExists is returned when creating a profile under a name that is already taken.
+var ErrProfileExists = errors.New("profile already exists")
+
+// profilesMu serializes load-modify-save cycles on the profiles file.
+var profilesMu sync.Mutex
+
+// Profile is a named set of maps to have enabled, such as the maps used for filming or for practice.
+type Profile struct {
+	Name      string       `json:"name"`
+	CreatedAt time.Time    `json:"created_at"`
+	Maps      []ProfileMap `json:"maps"`
+}
+
+// ProfileMap identifies a map in a profile. The name is kept so maps that are no longer installed can be shown.
+type ProfileMap struct {
+	MapID int    `json:"map_id"`
+	Name  string `json:"name"`
+}
+
+// ProfilePlan lists the changes that applying a profile makes.
+type ProfilePlan struct {
+	Enable  []*InstalledMap // Installed maps in the profile that are disabled
+	Disable []*InstalledMap // Enabled maps that are not in the profile
+	Missing []ProfileMap    // Maps in the profile that are not installed
+}
+
+// Empty reports whether the profile already matches the installed maps.
+func (p *ProfilePlan) Empty() bool {
+	return len(p.Enable) == 0 && len(p.Disable) == 0 && len(p.Missing) == 0
+}
+
+// LoadProfiles returns the saved profiles sorted by name. A missing file yields no profiles.
+func LoadProfiles() ([]*Profile, error) {
+	profilesPath, err := config.GetProfilesPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(profilesPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read profiles: %w", err)
+	}
+
+	var profiles []*Profile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal profiles: %w", err)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return strings.ToLower(profiles[i].Name) < strings.ToLower(profiles[j].Name) })
+	return profiles, nil
+}
+
+// updateProfiles loads the profiles, applies fn and saves the result while holding the profiles lock.
+func updateProfiles(fn func([]*Profile) ([]*Profile, error)) error {
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+
+	profiles, err := LoadProfiles()
+	if err != nil {
+		return err
+	}
+	if profiles, err = fn(profiles); err != nil {
+		return err
+	}
+	profilesPath, err := config.GetProfilesPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(profiles, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal profiles: %w", err)
+	}
+	if err := writeFileAtomic(profilesPath, data); err != nil {
+		return fmt.Errorf("failed to save profiles: %w", err)
+	}
+	return nil
+}
+
+// FindProfile returns the profile with the given name, compared case-insensitively.
+func FindProfile(name string) (*Profile, error) {
+	profiles, err := LoadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	if i := profileIndex(profiles, name); i >= 0 {
+		return profiles[i], nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNoProfile, name)
+}
+
+func profileIndex(profiles []*Profile, name string) int {
+	for i, p := range profiles {
+		if strings.EqualFold(p.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// CreateProfile saves the currently enabled maps as a profile. An existing profile with the same name is
+// only overwritten when replace is set.
+func CreateProfile(name string, replace bool) (*Profile, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("profile name cannot be empty")
+	}
+	manifest, err := LoadManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &Profile{Name: name, CreatedAt: time.Now().UTC(), Maps: []ProfileMap{}}
+	for _, rec := range manifest.Maps {
+		if rec.Enabled() {
+			profile.Maps = append(profile.Maps, ProfileMap{MapID: rec.MapID, Name: rec.Name})
+		}
+	}
+	sort.Slice(profile.Maps, func(i, j int) bool {
+		return strings.ToLower(profile.Maps[i].Name) < strings.ToLower(profile.Maps[j].Name)
+	})
+
+	err = updateProfiles(func(profiles []*Profile) ([]*Profile, error) {
+		i := profileIndex(profiles, name)
+		switch {
+		case i < 0:
+			return append(profiles, profile), nil
+		case replace:
+			profiles[i] = profile
+			return profiles, nil
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrProfileExists, profiles[i].Name)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	Logger.Printf("Saved profile '%s' with %d maps.", profile.Name, len(profile.Maps))
+	return profile, nil
+}
+
+// DeleteProfile removes the profile with the given name and returns it. Installed maps are not touched.
+func DeleteProfile(name string) (*Profile, error) {
+	var deleted *Profile
+	err := updateProfiles(func(profiles []*Profile) ([]*Profile, error) {
+		i := profileIndex(profiles, name)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrNoProfile, name)
+		}
+		deleted = profiles[i]
+		return append(profiles[:i], profiles[i+1:]...), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	Logger.Printf("Deleted profile '%s'.", deleted.Name)
+	return deleted, nil
+}
+
+// Plan compares the profile with the installed maps in manifest.
+func (p *Profile) Plan(manifest *Manifest) *ProfilePlan {
+	plan := &ProfilePlan{}
+	wanted := map[int]bool{}
+	for _, pm := range p.Maps {
+		wanted[pm.MapID] = true
+		rec := manifest.Get(pm.MapID)
+		switch {
+		case rec == nil:
+			plan.Missing = append(plan.Missing, pm)
+		case !rec.Enabled():
+			plan.Enable = append(plan.Enable, rec)
+		}
+	}
+	for _, rec := range manifest.Maps {
+		if !wanted[rec.MapID] && rec.Enabled() {
+			plan.Disable = append(plan.Disable, rec)
+		}
+	}
+	return plan
+}
+
+// Active reports whether exactly the maps in the profile are installed and enabled.
+func (p *Profile) Active(manifest *Manifest) bool {
+	return p.Plan(manifest).Empty()
+}
+
+// ApplyProfile disables the enabled maps that are not in the profile and enables the disabled ones that are.
+// It returns the plan it carried out; the maps in plan.Missing are left for the caller to install, since
+// they have to be downloaded. Maps that fail to switch are skipped and reported together in the error.
+func ApplyProfile(p *Profile) (*ProfilePlan, error) {
+	manifest, err := LoadManifest()
+	if err != nil {
+		return nil, err
+	}
+	plan := p.Plan(manifest)
+	Logger.Printf("Applying profile '%s': enabling %d, disabling %d, %d missing.", p.Name, len(plan.Enable), len(plan.Disable), len(plan.Missing))
+
+	var failures []error
+	for _, rec := range plan.Disable {
+		if _, err := SetEnabled(strconv.Itoa(rec.MapID), false); err != nil {
+			failures = append(failures, err)
+		}
+	}
+	for _, rec := range plan.Enable {
+		if _, err := SetEnabled(strconv.Itoa(rec.MapID), true); err != nil {
+			failures = append(failures, err)
+		}
+	}
+	return plan, errors.Join(failures...)
+}
@@ -0,0 +1,194 @@
+package installer
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/api"
+)
+
+// DefaultConcurrency is the number of maps installed in parallel when no concurrency is configured.
+const DefaultConcurrency = 2
+
+// QueueStatus is the lifecycle state of a queued install.
+type QueueStatus string
+
+const (
+	StatusPending     QueueStatus = "pending"
+	StatusDownloading QueueStatus = "downloading"
+	StatusVerifying   QueueStatus = "verifying"
+	StatusExtracting  QueueStatus = "extracting"
+	StatusDone        QueueStatus = "done"
+	StatusFailed      QueueStatus = "failed"
+)
+
+// QueueEvent reports a status or progress change for one queued map.
+type QueueEvent struct {
+	Map      api.Map
+	Status   QueueStatus
+	Progress ProgressMsg
+	Result   *InstalledMap // Set when Status is StatusDone
+	Err      error         // Set when Status is StatusFailed
+}
+
+// Queue installs maps on a pool of workers and reports progress as QueueEvents.
+type Queue struct {
+	ctx     context.Context
+	client  *api.Client
+	mapsDir string
+	events  chan QueueEvent
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []api.Map
+	active  map[int]bool   // Map IDs that are pending or being installed
+	local   map[int]string // Paths of queued local maps, by map ID
+	closed  bool
+	workers sync.WaitGroup
+}
+
+// NewQueue starts concurrency workers that install into mapsDir until ctx is cancelled or Close is called.
+func NewQueue(ctx context.Context, client *api.Client, mapsDir string, concurrency int) *Queue {
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	q := &Queue{
+		ctx:     ctx,
+		client:  client,
+		mapsDir: mapsDir,
+		events:  make(chan QueueEvent, 64),
+		active:  map[int]bool{},
+		local:   map[int]string{},
+	}
+	q.cond = sync.NewCond(&q.mu)
+
+	for i := 0; i < concurrency; i++ {
+		q.workers.Add(1)
+		go q.worker()
+	}
+	if done := ctx.Done(); done != nil {
+		go func() {
+			<-done
+			q.Close()
+		}()
+	}
+	go func() {
+		q.workers.Wait()
+		close(q.events)
+	}()
+	return q
+}
+
+// Events returns the channel of queue events. It is closed once Close has been called and all workers have finished.
+func (q *Queue) Events() <-chan QueueEvent {
+	return q.events
+}
+
+// Add queues m for installation with status StatusPending. It reports false if m is already queued or installing.
+// Add never blocks on the event channel, so it is safe to call from the goroutine that consumes Events.
+func (q *Queue) Add(m api.Map) bool {
+	q.mu.Lock()
+	if q.closed || q.active[m.ID] {
+		q.mu.Unlock()
+		return false
+	}
+	q.active[m.ID] = true
+	q.pending = append(q.pending, m)
+	q.mu.Unlock()
+
+	q.cond.Signal()
+	return true
+}
+
+// AddLocal queues the local archive or folder at path, installed as m from LocalMap. It reports false like Add.
+func (q *Queue) AddLocal(m api.Map, path string) bool {
+	q.mu.Lock()
+	if q.closed || q.active[m.ID] {
+		q.mu.Unlock()
+		return false
+	}
+	q.active[m.ID] = true
+	q.local[m.ID] = path
+	q.pending = append(q.pending, m)
+	q.mu.Unlock()
+
+	q.cond.Signal()
+	return true
+}
+
+// Close stops accepting new maps. Workers finish the maps already queued and then exit.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+// next waits for a queued map and returns it with the maps directory and, for local maps, the source path.
+func (q *Queue) next() (api.Map, string, string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.pending) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.pending) == 0 {
+		return api.Map{}, "", "", false
+	}
+	m := q.pending[0]
+	q.pending = q.pending[1:]
+	return m, q.mapsDir, q.local[m.ID], true
+}
+
+func (q *Queue) worker() {
+	defer q.workers.Done()
+	for {
+		m, mapsDir, localPath, ok := q.next()
+		if !ok {
+			return
+		}
+		q.install(m, mapsDir, localPath)
+
+		q.mu.Lock()
+		delete(q.active, m.ID)
+		delete(q.local, m.ID)
+		q.mu.Unlock()
+	}
+}
+
+func (q *Queue) install(m api.Map, mapsDir, localPath string) {
+	// Local maps have nothing to download and report StatusExtracting with their first progress message.
+	if localPath == "" {
+		q.events <- QueueEvent{Map: m, Status: StatusDownloading}
+	}
+
+	progressChan := make(chan ProgressMsg)
+	forwarded := make(chan struct{})
+	go func() {
+		defer close(forwarded)
+		for msg := range progressChan {
+			status := StatusDownloading
+			switch msg.Type {
+			case "verify":
+				status = StatusVerifying
+			case "extract":
+				status = StatusExtracting
+			}
+			q.events <- QueueEvent{Map: m, Status: status, Progress: msg}
+		}
+	}()
+
+	var rec *InstalledMap
+	var err error
+	if localPath != "" {
+		rec, err = InstallLocal(m, localPath, mapsDir, progressChan)
+	} else {
+		rec, err = InstallMap(q.ctx, q.client, m, mapsDir, progressChan)
+	}
+	<-forwarded
+	if err != nil {
+		Logger.Printf("Queue: failed to install '%s': %v", m.Name, err)
+		q.events <- QueueEvent{Map: m, Status: StatusFailed, Err: err}
+		return
+	}
+	q.events <- QueueEvent{Map: m, Status: StatusDone, Result: rec}
+}
@@ -0,0 +1,1075 @@
+package installer
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"unicode/utf16"
+
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// This file implements a reader for 7z archives. It covers what 7-Zip produces for map archives: LZMA and
+// LZMA2 compression, the x86 BCJ filter 7-Zip applies to executables, and the Copy, Deflate and BZip2
+// methods. Encrypted archives and multi-stream coders such as BCJ2 are rejected with a descriptive error.
+// The available Go 7z libraries size their tables by the counts in the header, so this reader exists to check
+// those counts against ExtractLimits first. FuzzSevenZip in sevenzip_test.go exercises it.
+
+var sevenZipSignature = []byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C}
+
+// Property IDs from the 7z format specification.
+const (
+	szEnd                   = 0x00
+	szHeader                = 0x01
+	szArchiveProperties     = 0x02
+	szAdditionalStreamsInfo = 0x03
+	szMainStreamsInfo       = 0x04
+	szFilesInfo             = 0x05
+	szPackInfo              = 0x06
+	szUnpackInfo            = 0x07
+	szSubStreamsInfo        = 0x08
+	szSize                  = 0x09
+	szCRC                   = 0x0A
+	szFolders               = 0x0B
+	szCodersUnpackSize      = 0x0C
+	szNumUnpackStream       = 0x0D
+	szEmptyStream           = 0x0E
+	szEmptyFile             = 0x0F
+	szName                  = 0x11
+	szWinAttributes         = 0x15
+	szEncodedHeader         = 0x17
+)
+
+// Coder method IDs.
+const (
+	szMethodCopy    = "\x00"
+	szMethodLZMA    = "\x03\x01\x01"
+	szMethodLZMA2   = "\x21"
+	szMethodBCJ     = "\x03\x03\x01\x03"
+	szMethodDeflate = "\x04\x01\x08"
+	szMethodBZip2   = "\x04\x02\x02"
+	szMethodAES     = "\x06\xf1\x07\x01"
+)
+
+const szSignatureHeaderSize = 32
+
+// szMaxHeaderSize bounds the decoded header so a corrupt archive cannot make us allocate arbitrary memory.
+const szMaxHeaderSize = 64 * 1024 * 1024
+
+// szMaxCount bounds every element count in the header, and the total number of substreams, whatever the
+// configured limits. A decoded header may be large enough to justify hundreds of millions of elements.
+const szMaxCount = 1 << 20
+
+// szMaxCoders bounds the coders of a folder and the streams of a coder. 7-Zip itself allows 64.
+const szMaxCoders = 64
+
+var errSevenZipCorrupt = errors.New("corrupt 7z archive")
+
+type szCoder struct {
+	method string
+	numIn  int
+	numOut int
+	props  []byte
+}
+
+type szBindPair struct {
+	in  int
+	out int
+}
+
+// szFolder is a unit of compressed data decoded by a chain of coders into one or more files.
+type szFolder struct {
+	coders          []szCoder
+	bindPairs       []szBindPair
+	packedStreams   []int    // Coder input indices fed directly from pack streams
+	unpackSizes     []uint64 // One per coder output
+	hasCRC          bool
+	crc             uint32
+	firstPackStream int
+	numSubstreams   int
+}
+
+// mainOutput returns the index of the output not consumed by another coder, which yields the folder's data.
+func (f *szFolder) mainOutput() int {
+	for out := range f.unpackSizes {
+		bound := false
+		for _, bp := range f.bindPairs {
+			if bp.out == out {
+				bound = true
+				break
+			}
+		}
+		if !bound {
+			return out
+		}
+	}
+	return -1
+}
+
+func (f *szFolder) unpackSize() uint64 {
+	if out := f.mainOutput(); out >= 0 {
+		return f.unpackSizes[out]
+	}
+	return 0
+}
+
+type szStreamsInfo struct {
+	packPos   uint64
+	packSizes []uint64
+	folders   []*szFolder
+	subSizes  []uint64 // Sizes of all substreams, in folder order
+	subHasCRC []bool
+	subCRCs   []uint32
+}
+
+// folderPackSize returns the compressed size of a folder.
+func (si *szStreamsInfo) folderPackSize(folder *szFolder) uint64 {
+	var size uint64
+	for i := range folder.packedStreams {
+		if index := folder.firstPackStream + i; index < len(si.packSizes) {
+			size += si.packSizes[index]
+		}
+	}
+	return size
+}
+
+type szFile struct {
+	name      string
+	hasStream bool
+	isDir     bool
+	size      uint64
+	packed    uint64 // Share of the folder's compressed size, in proportion to size
+	hasCRC    bool
+	crc       uint32
+}
+
+func (f *szFile) entry() archiveEntry {
+	return archiveEntry{Name: f.name, IsDir: f.isDir, Size: int64(f.size), Packed: int64(f.packed)}
+}
+
+// sevenZipArchive reads 7z files.
+type sevenZipArchive struct {
+	f       *os.File
+	limits  ExtractLimits
+	streams *szStreamsInfo
+	files   []szFile
+}
+
+// openSevenZip reads the headers of the 7z archive at path. A file count above limits.MaxEntries is rejected
+// with a *LimitError before the file list is allocated.
+func openSevenZip(path string, limits ExtractLimits) (*sevenZipArchive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	a := &sevenZipArchive{f: f, limits: limits, streams: &szStreamsInfo{}}
+	if err := a.readHeaders(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read 7z archive: %w", err)
+	}
+	return a, nil
+}
+
+func (a *sevenZipArchive) readHeaders() error {
+	info, err := a.f.Stat()
+	if err != nil {
+		return err
+	}
+	start := make([]byte, szSignatureHeaderSize)
+	if _, err := io.ReadFull(a.f, start); err != nil {
+		return err
+	}
+	if !bytes.Equal(start[:6], sevenZipSignature) {
+		return fmt.Errorf("%w: bad signature", errSevenZipCorrupt)
+	}
+	if crc32.ChecksumIEEE(start[12:32]) != binary.LittleEndian.Uint32(start[8:12]) {
+		return fmt.Errorf("%w: start header checksum mismatch", errSevenZipCorrupt)
+	}
+
+	nextOffset := binary.LittleEndian.Uint64(start[12:20])
+	nextSize := binary.LittleEndian.Uint64(start[20:28])
+	nextCRC := binary.LittleEndian.Uint32(start[28:32])
+	if nextSize == 0 {
+		return nil // Empty archive
+	}
+	if nextSize > szMaxHeaderSize || nextOffset > uint64(info.Size()) || szSignatureHeaderSize+nextOffset+nextSize > uint64(info.Size()) {
+		return fmt.Errorf("%w: header outside of file", errSevenZipCorrupt)
+	}
+
+	data := make([]byte, nextSize)
+	if _, err := a.f.ReadAt(data, int64(szSignatureHeaderSize+nextOffset)); err != nil {
+		return err
+	}
+	if crc32.ChecksumIEEE(data) != nextCRC {
+		return fmt.Errorf("%w: header checksum mismatch", errSevenZipCorrupt)
+	}
+
+	for {
+		buf := &szBuffer{data: data}
+		switch id := buf.readByte(); id {
+		case szHeader:
+			if err := a.readHeader(buf); err != nil {
+				return err
+			}
+			return buf.err
+		case szEncodedHeader:
+			// The real header is itself compressed and stored as the first folder of these streams.
+			streams, err := readStreamsInfo(buf)
+			if err != nil {
+				return err
+			}
+			if len(streams.folders) == 0 {
+				return fmt.Errorf("%w: encoded header without data", errSevenZipCorrupt)
+			}
+			folder := streams.folders[0]
+			if folder.unpackSize() > szMaxHeaderSize {
+				return fmt.Errorf("%w: header too large", errSevenZipCorrupt)
+			}
+			r, err := a.folderReader(streams, 0)
+			if err != nil {
+				return err
+			}
+			data, err = io.ReadAll(io.LimitReader(r, int64(folder.unpackSize())))
+			if err != nil {
+				return fmt.Errorf("failed to decode header: %w", err)
+			}
+			if folder.hasCRC && crc32.ChecksumIEEE(data) != folder.crc {
+				return fmt.Errorf("%w: header checksum mismatch", errSevenZipCorrupt)
+			}
+		default:
+			if buf.err != nil {
+				return buf.err
+			}
+			return fmt.Errorf("%w: unexpected header type %#x", errSevenZipCorrupt, id)
+		}
+	}
+}
+
+func (a *sevenZipArchive) readHeader(buf *szBuffer) error {
+	id := buf.readByte()
+	if id == szArchiveProperties {
+		for buf.err == nil {
+			if buf.readByte() == szEnd {
+				break
+			}
+			buf.readBytes(buf.readLength())
+		}
+		id = buf.readByte()
+	}
+	if id == szAdditionalStreamsInfo {
+		return fmt.Errorf("7z archives with additional streams are not supported")
+	}
+	if id == szMainStreamsInfo {
+		streams, err := readStreamsInfo(buf)
+		if err != nil {
+			return err
+		}
+		a.streams = streams
+		id = buf.readByte()
+	}
+	if id == szFilesInfo {
+		if err := a.readFilesInfo(buf); err != nil {
+			return err
+		}
+		id = buf.readByte()
+	}
+	if buf.err != nil {
+		return buf.err
+	}
+	if id != szEnd {
+		return fmt.Errorf("%w: unexpected property %#x in header", errSevenZipCorrupt, id)
+	}
+	return a.assignStreams()
+}
+
+func readStreamsInfo(buf *szBuffer) (*szStreamsInfo, error) {
+	si := &szStreamsInfo{}
+	id := buf.readByte()
+	if id == szPackInfo {
+		si.packPos = buf.readNumber()
+		numPackStreams := buf.readCount()
+		for id = buf.readByte(); id != szEnd && buf.err == nil; id = buf.readByte() {
+			switch id {
+			case szSize:
+				si.packSizes = make([]uint64, numPackStreams)
+				for i := range si.packSizes {
+					si.packSizes[i] = buf.readNumber()
+				}
+			case szCRC:
+				buf.readDigests(numPackStreams)
+			default:
+				return nil, fmt.Errorf("%w: unexpected property %#x in pack info", errSevenZipCorrupt, id)
+			}
+		}
+		if len(si.packSizes) != numPackStreams {
+			return nil, fmt.Errorf("%w: missing pack sizes", errSevenZipCorrupt)
+		}
+		id = buf.readByte()
+	}
+
+	if id == szUnpackInfo {
+		if err := si.readUnpackInfo(buf); err != nil {
+			return nil, err
+		}
+		id = buf.readByte()
+	}
+
+	for _, folder := range si.folders {
+		folder.numSubstreams = 1
+	}
+	if id == szSubStreamsInfo {
+		if err := si.readSubStreamsInfo(buf); err != nil {
+			return nil, err
+		}
+		id = buf.readByte()
+	} else {
+		for _, folder := range si.folders {
+			si.subSizes = append(si.subSizes, folder.unpackSize())
+			si.subHasCRC = append(si.subHasCRC, folder.hasCRC)
+			si.subCRCs = append(si.subCRCs, folder.crc)
+		}
+	}
+
+	if buf.err != nil {
+		return nil, buf.err
+	}
+	if id != szEnd {
+		return nil, fmt.Errorf("%w: unexpected property %#x in streams info", errSevenZipCorrupt, id)
+	}
+
+	packStream := 0
+	for _, folder := range si.folders {
+		folder.firstPackStream = packStream
+		packStream += len(folder.packedStreams)
+	}
+	if packStream > len(si.packSizes) {
+		return nil, fmt.Errorf("%w: folders use more pack streams than the archive has", errSevenZipCorrupt)
+	}
+	return si, nil
+}
+
+func (si *szStreamsInfo) readUnpackInfo(buf *szBuffer) error {
+	if buf.readByte() != szFolders {
+		return fmt.Errorf("%w: missing folder list", errSevenZipCorrupt)
+	}
+	numFolders := buf.readCount()
+	if buf.readByte() != 0 {
+		return fmt.Errorf("7z archives with external folder data are not supported")
+	}
+	si.folders = make([]*szFolder, numFolders)
+	for i := range si.folders {
+		folder, err := readFolder(buf)
+		if err != nil {
+			return err
+		}
+		si.folders[i] = folder
+	}
+
+	if buf.readByte() != szCodersUnpackSize {
+		return fmt.Errorf("%w: missing unpack sizes", errSevenZipCorrupt)
+	}
+	for _, folder := range si.folders {
+		for i := range folder.unpackSizes {
+			folder.unpackSizes[i] = buf.readNumber()
+		}
+	}
+
+	id := buf.readByte()
+	if id == szCRC {
+		defined, crcs := buf.readDigests(numFolders)
+		for i, folder := range si.folders {
+			folder.hasCRC, folder.crc = defined[i], crcs[i]
+		}
+		id = buf.readByte()
+	}
+	if buf.err != nil {
+		return buf.err
+	}
+	if id != szEnd {
+		return fmt.Errorf("%w: unexpected property %#x in unpack info", errSevenZipCorrupt, id)
+	}
+	return nil
+}
+
+func readFolder(buf *szBuffer) (*szFolder, error) {
+	folder := &szFolder{}
+	numCoders := buf.readCount()
+	if numCoders > szMaxCoders {
+		return nil, fmt.Errorf("%w: %d coders in a folder", errSevenZipCorrupt, numCoders)
+	}
+	totalIn, totalOut := 0, 0
+	for i := 0; i < numCoders && buf.err == nil; i++ {
+		flags := buf.readByte()
+		if flags&0x80 != 0 {
+			return nil, fmt.Errorf("7z archives with alternative coder methods are not supported")
+		}
+		coder := szCoder{method: string(buf.readBytes(int(flags & 0x0F))), numIn: 1, numOut: 1}
+		if flags&0x10 != 0 {
+			coder.numIn = buf.readCount()
+			coder.numOut = buf.readCount()
+			if coder.numIn > szMaxCoders || coder.numOut > szMaxCoders {
+				return nil, fmt.Errorf("%w: coder with %d inputs and %d outputs", errSevenZipCorrupt, coder.numIn, coder.numOut)
+			}
+		}
+		if flags&0x20 != 0 {
+			coder.props = buf.readBytes(buf.readLength())
+		}
+		totalIn += coder.numIn
+		totalOut += coder.numOut
+		folder.coders = append(folder.coders, coder)
+	}
+	if buf.err != nil {
+		return nil, buf.err
+	}
+	if totalOut == 0 || totalIn < totalOut-1 {
+		return nil, fmt.Errorf("%w: invalid coder layout", errSevenZipCorrupt)
+	}
+
+	for i := 0; i < totalOut-1; i++ {
+		folder.bindPairs = append(folder.bindPairs, szBindPair{in: int(buf.readNumber()), out: int(buf.readNumber())})
+	}
+	numPacked := totalIn - (totalOut - 1)
+	if numPacked == 1 {
+		for in := 0; in < totalIn; in++ {
+			bound := false
+			for _, bp := range folder.bindPairs {
+				if bp.in == in {
+					bound = true
+					break
+				}
+			}
+			if !bound {
+				folder.packedStreams = append(folder.packedStreams, in)
+				break
+			}
+		}
+	} else {
+		for i := 0; i < numPacked; i++ {
+			folder.packedStreams = append(folder.packedStreams, int(buf.readNumber()))
+		}
+	}
+	folder.unpackSizes = make([]uint64, totalOut)
+	return folder, buf.err
+}
+
+func (si *szStreamsInfo) readSubStreamsInfo(buf *szBuffer) error {
+	id := buf.readByte()
+	if id == szNumUnpackStream {
+		total := 0
+		for _, folder := range si.folders {
+			folder.numSubstreams = buf.readCount()
+			if total += folder.numSubstreams; total > szMaxCount {
+				return fmt.Errorf("%w: more than %d substreams", errSevenZipCorrupt, szMaxCount)
+			}
+		}
+		id = buf.readByte()
+	}
+
+	for _, folder := range si.folders {
+		if folder.numSubstreams == 0 {
+			continue
+		}
+		if folder.numSubstreams > 1 && id != szSize {
+			return fmt.Errorf("%w: missing substream sizes", errSevenZipCorrupt)
+		}
+		var sum uint64
+		for i := 1; i < folder.numSubstreams && id == szSize; i++ {
+			size := buf.readNumber()
+			si.subSizes = append(si.subSizes, size)
+			sum += size
+		}
+		if sum > folder.unpackSize() {
+			return fmt.Errorf("%w: substreams larger than their folder", errSevenZipCorrupt)
+		}
+		si.subSizes = append(si.subSizes, folder.unpackSize()-sum)
+	}
+	if id == szSize {
+		id = buf.readByte()
+	}
+
+	// Digests are listed only for substreams whose CRC is not already known from the folder.
+	numDigests := 0
+	for _, folder := range si.folders {
+		if folder.numSubstreams != 1 || !folder.hasCRC {
+			numDigests += folder.numSubstreams
+		}
+	}
+	var defined []bool
+	var crcs []uint32
+	if id == szCRC {
+		defined, crcs = buf.readDigests(numDigests)
+		id = buf.readByte()
+	}
+	digest := 0
+	for _, folder := range si.folders {
+		if folder.numSubstreams == 1 && folder.hasCRC {
+			si.subHasCRC = append(si.subHasCRC, true)
+			si.subCRCs = append(si.subCRCs, folder.crc)
+			continue
+		}
+		for i := 0; i < folder.numSubstreams; i++ {
+			if digest < len(defined) && defined[digest] {
+				si.subHasCRC = append(si.subHasCRC, true)
+				si.subCRCs = append(si.subCRCs, crcs[digest])
+			} else {
+				si.subHasCRC = append(si.subHasCRC, false)
+				si.subCRCs = append(si.subCRCs, 0)
+			}
+			digest++
+		}
+	}
+
+	if buf.err != nil {
+		return buf.err
+	}
+	if id != szEnd {
+		return fmt.Errorf("%w: unexpected property %#x in substreams info", errSevenZipCorrupt, id)
+	}
+	return nil
+}
+
+func (a *sevenZipArchive) readFilesInfo(buf *szBuffer) error {
+	numFiles := buf.readCount()
+	if a.limits.MaxEntries > 0 && numFiles > a.limits.MaxEntries {
+		return &LimitError{Limit: "entries", Actual: fmt.Sprint(numFiles), Max: fmt.Sprint(a.limits.MaxEntries)}
+	}
+	if buf.err != nil {
+		return buf.err
+	}
+	a.files = make([]szFile, numFiles)
+	var emptyStream, emptyFile []bool
+	numEmpty := 0
+
+	for buf.err == nil {
+		propType := buf.readByte()
+		if propType == szEnd {
+			break
+		}
+		prop := &szBuffer{data: buf.readBytes(buf.readLength())}
+		switch propType {
+		case szEmptyStream:
+			emptyStream = prop.readBits(numFiles)
+			numEmpty = 0
+			for _, empty := range emptyStream {
+				if empty {
+					numEmpty++
+				}
+			}
+		case szEmptyFile:
+			emptyFile = prop.readBits(numEmpty)
+		case szName:
+			if prop.readByte() != 0 {
+				return fmt.Errorf("7z archives with external file names are not supported")
+			}
+			names := prop.readBytes(len(prop.data) - prop.pos)
+			for i := range a.files {
+				var name []uint16
+				for len(names) >= 2 {
+					c := binary.LittleEndian.Uint16(names)
+					names = names[2:]
+					if c == 0 {
+						break
+					}
+					name = append(name, c)
+				}
+				a.files[i].name = string(utf16.Decode(name))
+			}
+		case szWinAttributes:
+			defined := prop.readDefined(numFiles)
+			if prop.readByte() != 0 {
+				return fmt.Errorf("7z archives with external attributes are not supported")
+			}
+			for i, ok := range defined {
+				// FILE_ATTRIBUTE_DIRECTORY
+				if ok && prop.readUint32()&0x10 != 0 {
+					a.files[i].isDir = true
+				}
+			}
+		}
+		if prop.err != nil {
+			return prop.err
+		}
+	}
+
+	empty := 0
+	for i := range a.files {
+		a.files[i].hasStream = true
+		if i < len(emptyStream) && emptyStream[i] {
+			a.files[i].hasStream = false
+			if empty >= len(emptyFile) || !emptyFile[empty] {
+				a.files[i].isDir = true
+			}
+			empty++
+		}
+	}
+	return buf.err
+}
+
+// assignStreams gives every file with data its size and checksum from the substream list.
+func (a *sevenZipArchive) assignStreams() error {
+	stream := 0
+	folderIndex := -1
+	remaining := 0 // Substreams left in the current folder
+	for i := range a.files {
+		file := &a.files[i]
+		if !file.hasStream {
+			continue
+		}
+		if stream >= len(a.streams.subSizes) {
+			return fmt.Errorf("%w: more files than streams", errSevenZipCorrupt)
+		}
+		for remaining == 0 && folderIndex+1 < len(a.streams.folders) {
+			folderIndex++
+			remaining = a.streams.folders[folderIndex].numSubstreams
+		}
+		remaining--
+		file.size = a.streams.subSizes[stream]
+		if folderIndex >= 0 {
+			folder := a.streams.folders[folderIndex]
+			if unpacked := folder.unpackSize(); unpacked > 0 {
+				file.packed = uint64(float64(a.streams.folderPackSize(folder)) * float64(file.size) / float64(unpacked))
+			}
+		}
+		file.hasCRC = a.streams.subHasCRC[stream]
+		file.crc = a.streams.subCRCs[stream]
+		stream++
+	}
+	return nil
+}
+
+func (a *sevenZipArchive) Entries() ([]archiveEntry, error) {
+	entries := make([]archiveEntry, 0, len(a.files))
+	for _, file := range a.files {
+		entries = append(entries, file.entry())
+	}
+	return entries, nil
+}
+
+func (a *sevenZipArchive) Walk(fn func(entry archiveEntry, r io.Reader) error) error {
+	folderIndex := -1
+	remaining := 0 // Substreams left in the current folder
+	var folder io.Reader
+
+	for _, file := range a.files {
+		entry := file.entry()
+		if !file.hasStream {
+			var contents io.Reader
+			if !file.isDir {
+				contents = bytes.NewReader(nil)
+			}
+			if err := fn(entry, contents); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Files are stored back to back in the folders, so advance to the next folder once one is used up.
+		for remaining == 0 {
+			folderIndex++
+			if folderIndex >= len(a.streams.folders) {
+				return fmt.Errorf("%w: more files than folders", errSevenZipCorrupt)
+			}
+			remaining = a.streams.folders[folderIndex].numSubstreams
+			if remaining == 0 {
+				continue
+			}
+			r, err := a.folderReader(a.streams, folderIndex)
+			if err != nil {
+				return err
+			}
+			folder = r
+		}
+		remaining--
+
+		contents := &szFileReader{r: io.LimitReader(folder, int64(file.size)), crc: crc32.NewIEEE()}
+		if err := fn(entry, contents); err != nil {
+			return err
+		}
+		// Skip whatever fn left unread so the next file starts at the right offset.
+		if _, err := io.Copy(io.Discard, contents); err != nil {
+			return fmt.Errorf("failed to decompress '%s': %w", file.name, err)
+		}
+		if contents.n != int64(file.size) {
+			return fmt.Errorf("%w: '%s' is truncated", errSevenZipCorrupt, file.name)
+		}
+		if file.hasCRC && contents.crc.Sum32() != file.crc {
+			return fmt.Errorf("%w: checksum mismatch for '%s'", errSevenZipCorrupt, file.name)
+		}
+	}
+	return nil
+}
+
+func (a *sevenZipArchive) Close() error {
+	return a.f.Close()
+}
+
+// szFileReader counts and checksums the data of one file as it is read.
+type szFileReader struct {
+	r   io.Reader
+	crc hash.Hash32
+	n   int64
+}
+
+func (r *szFileReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.crc.Write(p[:n])
+	r.n += int64(n)
+	return n, err
+}
+
+// folderReader returns a reader for the decoded data of folder index in si.
+func (a *sevenZipArchive) folderReader(si *szStreamsInfo, index int) (io.Reader, error) {
+	folder := si.folders[index]
+	offset := int64(szSignatureHeaderSize + si.packPos)
+	for i := 0; i < folder.firstPackStream; i++ {
+		offset += int64(si.packSizes[i])
+	}
+	packed := make([]io.Reader, len(folder.packedStreams))
+	for i := range packed {
+		size := int64(si.packSizes[folder.firstPackStream+i])
+		packed[i] = io.NewSectionReader(a.f, offset, size)
+		offset += size
+	}
+
+	for _, coder := range folder.coders {
+		if coder.numIn != 1 || coder.numOut != 1 {
+			return nil, fmt.Errorf("unsupported 7z compression method %x", coder.method)
+		}
+	}
+	main := folder.mainOutput()
+	if main < 0 {
+		return nil, fmt.Errorf("%w: folder has no output", errSevenZipCorrupt)
+	}
+	return coderReader(folder, main, packed, 0)
+}
+
+// coderReader returns a reader for the output of coder i. Every coder has exactly one input and one output,
+// so coder, input and output indices coincide.
+func coderReader(folder *szFolder, i int, packed []io.Reader, depth int) (io.Reader, error) {
+	if depth > len(folder.coders) || i >= len(folder.coders) {
+		return nil, fmt.Errorf("%w: invalid coder chain", errSevenZipCorrupt)
+	}
+
+	var input io.Reader
+	for _, bp := range folder.bindPairs {
+		if bp.in == i {
+			r, err := coderReader(folder, bp.out, packed, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			input = r
+		}
+	}
+	for j, in := range folder.packedStreams {
+		if in == i {
+			input = packed[j]
+		}
+	}
+	if input == nil {
+		return nil, fmt.Errorf("%w: coder without input", errSevenZipCorrupt)
+	}
+
+	r, err := newSevenZipDecoder(folder.coders[i], input, folder.unpackSizes[i])
+	if err != nil {
+		return nil, err
+	}
+	return io.LimitReader(r, int64(folder.unpackSizes[i])), nil
+}
+
+// newSevenZipDecoder wraps r with the decompressor or filter for coder.
+func newSevenZipDecoder(coder szCoder, r io.Reader, size uint64) (io.Reader, error) {
+	switch coder.method {
+	case szMethodCopy:
+		return r, nil
+	case szMethodLZMA:
+		if len(coder.props) != 5 {
+			return nil, fmt.Errorf("%w: invalid LZMA properties", errSevenZipCorrupt)
+		}
+		// Rebuild the header of a standalone .lzma stream around the coder properties.
+		header := make([]byte, lzma.HeaderLen)
+		header[0] = coder.props[0]
+		binary.LittleEndian.PutUint32(header[1:5], uint32(szDictCap(int64(binary.LittleEndian.Uint32(coder.props[1:5])), size)))
+		binary.LittleEndian.PutUint64(header[5:], size)
+		return lzma.NewReader(io.MultiReader(bytes.NewReader(header), r))
+	case szMethodLZMA2:
+		if len(coder.props) != 1 || coder.props[0] > 40 {
+			return nil, fmt.Errorf("%w: invalid LZMA2 properties", errSevenZipCorrupt)
+		}
+		dictCap := int64(0xFFFFFFFF)
+		if p := coder.props[0]; p < 40 {
+			dictCap = int64(2|p&1) << (p/2 + 11)
+		}
+		return lzma.Reader2Config{DictCap: szDictCap(dictCap, size)}.NewReader2(r)
+	case szMethodBCJ:
+		return &bcjReader{r: r}, nil
+	case szMethodDeflate:
+		return flate.NewReader(r), nil
+	case szMethodBZip2:
+		return bzip2.NewReader(r), nil
+	case szMethodAES:
+		return nil, fmt.Errorf("encrypted 7z archives are not supported")
+	default:
+		return nil, fmt.Errorf("unsupported 7z compression method %x", coder.method)
+	}
+}
+
+// szDictCap limits an LZMA dictionary to the size of the data it decodes, so small archives created
+// with huge dictionary settings do not allocate that much memory.
+func szDictCap(dictCap int64, size uint64) int {
+	if size < uint64(dictCap) {
+		dictCap = int64(size)
+	}
+	if dictCap < lzma.MinDictCap {
+		dictCap = lzma.MinDictCap
+	}
+	return int(dictCap)
+}
+
+// szBuffer decodes the 7z header encoding. The first error is sticky: later reads return zero values,
+// so callers can check err once after a group of reads.
+type szBuffer struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (b *szBuffer) fail() {
+	if b.err == nil {
+		b.err = fmt.Errorf("%w: truncated header", errSevenZipCorrupt)
+	}
+}
+
+func (b *szBuffer) readByte() byte {
+	if b.err != nil || b.pos >= len(b.data) {
+		b.fail()
+		return 0
+	}
+	c := b.data[b.pos]
+	b.pos++
+	return c
+}
+
+func (b *szBuffer) readBytes(n int) []byte {
+	if b.err != nil || n < 0 || n > len(b.data)-b.pos {
+		b.fail()
+		return nil
+	}
+	p := b.data[b.pos : b.pos+n]
+	b.pos += n
+	return p
+}
+
+func (b *szBuffer) readUint32() uint32 {
+	p := b.readBytes(4)
+	if p == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(p)
+}
+
+// readNumber decodes the variable length integers used throughout the header. The number of leading one
+// bits in the first byte gives the count of extra little-endian bytes.
+func (b *szBuffer) readNumber() uint64 {
+	first := b.readByte()
+	var value uint64
+	mask := byte(0x80)
+	for i := 0; i < 8; i++ {
+		if first&mask == 0 {
+			return value | uint64(first&(mask-1))<<(8*i)
+		}
+		value |= uint64(b.readByte()) << (8 * i)
+		mask >>= 1
+	}
+	return value
+}
+
+// readCount reads a number used as an element count. Every element takes at least one byte or bit of
+// header, so counts larger than the header, or than szMaxCount, are rejected before anything is allocated
+// for them.
+func (b *szBuffer) readCount() int {
+	n := b.readNumber()
+	if b.err != nil {
+		return 0
+	}
+	if n > uint64(len(b.data))*8 || n > szMaxCount {
+		b.err = fmt.Errorf("%w: count %d out of range", errSevenZipCorrupt, n)
+		return 0
+	}
+	return int(n)
+}
+
+// readLength reads the size in bytes of a property that follows in the header.
+func (b *szBuffer) readLength() int {
+	n := b.readNumber()
+	if n > uint64(len(b.data)-b.pos) {
+		b.fail()
+		return 0
+	}
+	return int(n)
+}
+
+func (b *szBuffer) readBits(n int) []bool {
+	bits := make([]bool, n)
+	var c byte
+	for i := range bits {
+		if i%8 == 0 {
+			c = b.readByte()
+		}
+		bits[i] = c&(0x80>>(i%8)) != 0
+	}
+	return bits
+}
+
+// readDefined reads the "all defined" flag followed, if it is zero, by a bit vector.
+func (b *szBuffer) readDefined(n int) []bool {
+	if b.readByte() == 0 {
+		return b.readBits(n)
+	}
+	defined := make([]bool, n)
+	for i := range defined {
+		defined[i] = true
+	}
+	return defined
+}
+
+func (b *szBuffer) readDigests(n int) ([]bool, []uint32) {
+	defined := b.readDefined(n)
+	crcs := make([]uint32, n)
+	for i, ok := range defined {
+		if ok {
+			crcs[i] = b.readUint32()
+		}
+	}
+	return defined, crcs
+}
+
+// bcjReader reverses the x86 BCJ filter, which 7-Zip applies to executables to make relative call and
+// jump targets compress better. The conversion follows x86_Convert from the LZMA SDK.
+type bcjReader struct {
+	r         io.Reader
+	buf       []byte
+	converted int // Bytes at the start of buf that are ready to be returned
+	ip        uint32
+	state     uint32
+	eof       bool
+}
+
+const bcjBufferSize = 64 * 1024
+
+func (b *bcjReader) Read(p []byte) (int, error) {
+	for b.converted == 0 {
+		if b.eof {
+			if len(b.buf) == 0 {
+				return 0, io.EOF
+			}
+			// The last few bytes cannot hold an instruction and are passed through unchanged.
+			b.converted = len(b.buf)
+			break
+		}
+
+		if cap(b.buf) < bcjBufferSize {
+			b.buf = append(make([]byte, 0, bcjBufferSize), b.buf...)
+		} else {
+			b.buf = append(b.buf[:0:cap(b.buf)], b.buf...)
+		}
+		n, err := b.r.Read(b.buf[len(b.buf):cap(b.buf)])
+		b.buf = b.buf[:len(b.buf)+n]
+		if err == io.EOF {
+			b.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+
+		converted := x86Convert(b.buf, b.ip, &b.state)
+		b.ip += uint32(converted)
+		b.converted = converted
+	}
+
+	n := copy(p, b.buf[:b.converted])
+	b.buf = b.buf[n:]
+	b.converted -= n
+	return n, nil
+}
+
+// x86Convert decodes BCJ-filtered data in place and returns how many bytes are final.
+func x86Convert(data []byte, ip uint32, state *uint32) int {
+	maskToAllowed := [8]bool{true, true, true, false, true, false, false, false}
+	maskToBitNumber := [8]uint32{0, 1, 2, 2, 3, 3, 3, 3}
+	isMSByte := func(b byte) bool { return b == 0 || b == 0xFF }
+
+	size := len(data)
+	if size < 5 {
+		return 0
+	}
+	ip += 5
+	bufferPos := 0
+	prevPos := -1
+	prevMask := *state & 0x7
+
+	for {
+		p := bufferPos
+		for p < size-4 && data[p]&0xFE != 0xE8 {
+			p++
+		}
+		bufferPos = p
+		if p >= size-4 {
+			break
+		}
+
+		if bufferPos-prevPos > 3 {
+			prevMask = 0
+		} else {
+			prevMask = (prevMask << uint(bufferPos-prevPos-1)) & 0x7
+			if prevMask != 0 {
+				b := data[p+4-int(maskToBitNumber[prevMask])]
+				if !maskToAllowed[prevMask] || isMSByte(b) {
+					prevPos = bufferPos
+					prevMask = ((prevMask << 1) & 0x7) | 1
+					bufferPos++
+					continue
+				}
+			}
+		}
+		prevPos = bufferPos
+
+		if !isMSByte(data[p+4]) {
+			prevMask = ((prevMask << 1) & 0x7) | 1
+			bufferPos++
+			continue
+		}
+
+		src := binary.LittleEndian.Uint32(data[p+1 : p+5])
+		var dest uint32
+		for {
+			dest = src - (ip + uint32(bufferPos))
+			if prevMask == 0 {
+				break
+			}
+			index := maskToBitNumber[prevMask] * 8
+			if !isMSByte(byte(dest >> (24 - index))) {
+				break
+			}
+			src = dest ^ (1<<(32-index) - 1)
+		}
+		dest &= 0x01FFFFFF
+		if dest&0x01000000 != 0 {
+			dest |= 0xFF000000
+		}
+		binary.LittleEndian.PutUint32(data[p+1:p+5], dest)
+		bufferPos += 5
+	}
+
+	if bufferPos-prevPos > 3 {
+		*state = 0
+	} else {
+		*state = (prevMask << uint(bufferPos-prevPos-1)) & 0x7
+	}
+	return bufferPos
+}
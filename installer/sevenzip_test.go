@@ -0,0 +1,374 @@
+package installer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// szNumber encodes v in the variable length format of 7z headers.
+func szNumber(v uint64) []byte {
+	for n := 0; n < 8; n++ {
+		if v < 1<<(7*(n+1)) {
+			p := []byte{byte(0xFF<<(8-n)) | byte(v>>(8*n))}
+			for i := 0; i < n; i++ {
+				p = append(p, byte(v>>(8*i)))
+			}
+			return p
+		}
+	}
+	p := []byte{0xFF}
+	return binary.LittleEndian.AppendUint64(p, v)
+}
+
+// writeSevenZip builds a 7z archive of packed data followed by header, and returns its path.
+func writeSevenZip(t *testing.T, dir string, packed, header []byte) string {
+	t.Helper()
+	start := make([]byte, szSignatureHeaderSize)
+	copy(start, sevenZipSignature)
+	start[7] = 4 // Format version 0.4
+	binary.LittleEndian.PutUint64(start[12:20], uint64(len(packed)))
+	binary.LittleEndian.PutUint64(start[20:28], uint64(len(header)))
+	binary.LittleEndian.PutUint32(start[28:32], crc32.ChecksumIEEE(header))
+	binary.LittleEndian.PutUint32(start[8:12], crc32.ChecksumIEEE(start[12:32]))
+
+	path := filepath.Join(dir, "map.7z")
+	data := append(append(start, packed...), header...)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// encodeHeader compresses header with LZMA2 and returns the packed data and the encoded header pointing at it.
+func encodeHeader(t *testing.T, header []byte) ([]byte, []byte) {
+	t.Helper()
+	var packed bytes.Buffer
+	w, err := lzma.Writer2Config{DictCap: 1 << 20}.NewWriter2(&packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(header)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	encoded := []byte{szEncodedHeader, szPackInfo}
+	encoded = append(encoded, szNumber(0)...) // Pack position
+	encoded = append(encoded, szNumber(1)...) // Pack streams
+	encoded = append(encoded, szSize)
+	encoded = append(encoded, szNumber(uint64(packed.Len()))...)
+	encoded = append(encoded, szEnd, szUnpackInfo, szFolders)
+	encoded = append(encoded, szNumber(1)...)       // Folders
+	encoded = append(encoded, 0)                    // Not external
+	encoded = append(encoded, 1, 0x21, 0x21, 1, 16) // One LZMA2 coder with a 1 MiB dictionary
+	encoded = append(encoded, szCodersUnpackSize)
+	encoded = append(encoded, szNumber(uint64(len(header)))...)
+	encoded = append(encoded, szEnd, szEnd)
+	return packed.Bytes(), encoded
+}
+
+// filesHeader returns a plain header whose file list claims count files, padded to size bytes.
+func filesHeader(count uint64, size int) []byte {
+	header := []byte{szHeader, szFilesInfo}
+	header = append(header, szNumber(count)...)
+	header = append(header, szEnd, szEnd)
+	return append(header, make([]byte, max(size-len(header), 0))...)
+}
+
+func TestSevenZipHeaderCounts(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  ExtractLimits
+		header  []byte
+		encoded bool
+		limit   string // Expected LimitError.Limit, empty for a corrupt archive error
+	}{
+		{
+			name:   "more files than MaxEntries",
+			limits: DefaultExtractLimits,
+			header: filesHeader(30000, 4096),
+			limit:  "entries",
+		},
+		{
+			name:   "more files than the header holds",
+			limits: ExtractLimits{},
+			header: filesHeader(1<<40, 64),
+		},
+		{
+			// A small archive whose header decodes to 8 MiB, enough to justify 64M elements.
+			name:    "encoded header claiming 60M files",
+			limits:  ExtractLimits{},
+			header:  filesHeader(60<<20, 8<<20),
+			encoded: true,
+		},
+		{
+			name:    "encoded header claiming 60M files with limits",
+			limits:  DefaultExtractLimits,
+			header:  filesHeader(60<<20, 8<<20),
+			encoded: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packed, header := []byte(nil), tt.header
+			if tt.encoded {
+				packed, header = encodeHeader(t, tt.header)
+				if len(packed)+len(header) > 64<<10 {
+					t.Fatalf("crafted archive is %d bytes, want a small one", len(packed)+len(header))
+				}
+			}
+			src := writeSevenZip(t, t.TempDir(), packed, header)
+
+			a, err := openSevenZip(src, tt.limits)
+			if err == nil {
+				a.Close()
+				t.Fatalf("openSevenZip() succeeded with %d files", len(a.files))
+			}
+			var limitErr *LimitError
+			if tt.limit != "" {
+				if !errors.As(err, &limitErr) || limitErr.Limit != tt.limit {
+					t.Fatalf("openSevenZip() error = %v, want a %s *LimitError", err, tt.limit)
+				}
+				return
+			}
+			if !errors.Is(err, errSevenZipCorrupt) || !strings.Contains(err.Error(), "count") {
+				t.Fatalf("openSevenZip() error = %v, want a count out of range error", err)
+			}
+		})
+	}
+}
+
+// buildHeader returns a plain header for one folder holding files with the given names. Without subStreams,
+// the folder holds a single stream.
+func buildHeader(packSizes []uint64, folder []byte, unpackSizes []uint64, subStreams []byte, names ...string) []byte {
+	header := []byte{szHeader, szMainStreamsInfo, szPackInfo}
+	header = append(header, szNumber(0)...)
+	header = append(header, szNumber(uint64(len(packSizes)))...)
+	header = append(header, szSize)
+	for _, size := range packSizes {
+		header = append(header, szNumber(size)...)
+	}
+	header = append(header, szEnd, szUnpackInfo, szFolders)
+	header = append(header, szNumber(1)...)
+	header = append(header, 0) // Not external
+	header = append(header, folder...)
+	header = append(header, szCodersUnpackSize)
+	for _, size := range unpackSizes {
+		header = append(header, szNumber(size)...)
+	}
+	header = append(header, szEnd)
+	header = append(header, subStreams...)
+	header = append(header, szEnd, szFilesInfo)
+	header = append(header, szNumber(uint64(len(names)))...)
+
+	nameProp := []byte{0} // Not external
+	for _, name := range names {
+		for _, c := range name + "\x00" {
+			nameProp = binary.LittleEndian.AppendUint16(nameProp, uint16(c))
+		}
+	}
+	header = append(header, szName)
+	header = append(header, szNumber(uint64(len(nameProp)))...)
+	header = append(header, nameProp...)
+	return append(header, szEnd, szEnd)
+}
+
+// copyFolder is a folder with a single Copy coder.
+var copyFolder = []byte{1, 0x01, 0x00}
+
+func TestSevenZipCorruptHeaders(t *testing.T) {
+	data := []byte("cool park")
+	valid := buildHeader([]uint64{9}, copyFolder, []uint64{9}, nil, "cool park")
+	tests := []struct {
+		name   string
+		header []byte
+		want   string
+	}{
+		{"unexpected header type", []byte{0x42}, "unexpected header type"},
+		{"truncated header", valid[:len(valid)-4], "truncated header"},
+		{"unexpected property", []byte{szHeader, 0x30}, "unexpected property"},
+		{"empty encoded header", []byte{szEncodedHeader, szEnd}, "encoded header without data"},
+		{
+			"invalid coder layout",
+			buildHeader([]uint64{9}, []byte{1, 0x11, 0x00, 1, 0}, nil, nil, "cool park"),
+			"invalid coder layout",
+		},
+		{
+			"too many coders",
+			buildHeader([]uint64{9}, append([]byte{65}, bytes.Repeat([]byte{0x01, 0x00}, 65)...), nil, nil, "cool park"),
+			"coders in a folder",
+		},
+		{
+			"missing pack streams",
+			buildHeader(nil, copyFolder, []uint64{9}, nil, "cool park"),
+			"more pack streams than the archive has",
+		},
+		{
+			"substreams larger than their folder",
+			buildHeader([]uint64{9}, copyFolder, []uint64{9}, []byte{szSubStreamsInfo, szNumUnpackStream, 2, szSize, 10, szEnd}, "cool", "park"),
+			"substreams larger than their folder",
+		},
+		{
+			"more files than streams",
+			buildHeader([]uint64{9}, copyFolder, []uint64{9}, nil, "cool", "park"),
+			"more files than streams",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := writeSevenZip(t, t.TempDir(), data, tt.header)
+			a, err := openSevenZip(src, DefaultExtractLimits)
+			if err == nil {
+				a.Close()
+				t.Fatal("openSevenZip() succeeded on a corrupt header")
+			}
+			if !errors.Is(err, errSevenZipCorrupt) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("openSevenZip() error = %v, want a corrupt archive error containing %q", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		dest := t.TempDir()
+		if err := extractArchive(writeSevenZip(t, t.TempDir(), data, valid), dest, nil); err != nil {
+			t.Fatalf("extractArchive() error = %v", err)
+		}
+		if got, _ := os.ReadFile(filepath.Join(dest, "cool park")); string(got) != string(data) {
+			t.Errorf("extracted %q, want %q", got, data)
+		}
+	})
+}
+
+func TestSevenZipStartHeader(t *testing.T) {
+	header := buildHeader([]uint64{9}, copyFolder, []uint64{9}, nil, "cool park")
+	tests := map[string]struct {
+		mutate func(start []byte)
+		want   string
+	}{
+		"bad signature":         {func(start []byte) { start[0] = '8' }, "bad signature"},
+		"start header checksum": {func(start []byte) { start[20]++ }, "start header checksum mismatch"},
+		"header outside of file": {
+			func(start []byte) {
+				binary.LittleEndian.PutUint64(start[20:28], 1<<20)
+				binary.LittleEndian.PutUint32(start[8:12], crc32.ChecksumIEEE(start[12:32]))
+			},
+			"header outside of file",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			src := writeSevenZip(t, t.TempDir(), []byte("cool park"), header)
+			data, err := os.ReadFile(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.mutate(data[:szSignatureHeaderSize])
+			if err := os.WriteFile(src, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err = openSevenZip(src, DefaultExtractLimits)
+			if !errors.Is(err, errSevenZipCorrupt) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("openSevenZip() error = %v, want a corrupt archive error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSevenZipUnsupported(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		want   string
+	}{
+		{
+			name:   "AES encryption",
+			header: buildHeader([]uint64{9}, []byte{1, 0x24, 0x06, 0xf1, 0x07, 0x01, 1, 0}, []uint64{9}, nil, "cool park"),
+			want:   "encrypted 7z archives are not supported",
+		},
+		{
+			// BCJ2 reads four pack streams into one output.
+			name:   "BCJ2",
+			header: buildHeader([]uint64{3, 2, 2, 2}, []byte{1, 0x14, 0x03, 0x03, 0x01, 0x1b, 4, 1, 0, 1, 2, 3}, []uint64{9}, nil, "cool park"),
+			want:   "unsupported 7z compression method 0303011b",
+		},
+		{
+			name:   "unknown method",
+			header: buildHeader([]uint64{9}, []byte{1, 0x01, 0x7f}, []uint64{9}, nil, "cool park"),
+			want:   "unsupported 7z compression method 7f",
+		},
+		{
+			name:   "alternative methods",
+			header: buildHeader([]uint64{9}, []byte{1, 0x81, 0x00}, []uint64{9}, nil, "cool park"),
+			want:   "alternative coder methods are not supported",
+		},
+		{
+			name:   "additional streams",
+			header: []byte{szHeader, szAdditionalStreamsInfo},
+			want:   "additional streams are not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := writeSevenZip(t, dir, []byte("cool park"), tt.header)
+			dest := filepath.Join(dir, "extracted")
+			err := extractArchive(src, dest, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("extractArchive() error = %v, want %q", err, tt.want)
+			}
+			if _, err := os.Stat(dest); !os.IsNotExist(err) {
+				t.Errorf("extraction directory left behind")
+			}
+		})
+	}
+}
+
+// FuzzSevenZip feeds mutated archives to the 7z reader, which must fail cleanly rather than panic, hang or
+// write outside the extraction directory. The fuzzer mutates the packed data and the header separately, and
+// the checksums are fixed up, so the mutations reach the header parser and the decoders. Run it with
+// go test -run '^$' -fuzz FuzzSevenZip ./installer.
+func FuzzSevenZip(f *testing.F) {
+	seeds, err := filepath.Glob(filepath.Join("testdata", "*.7z"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, seed := range seeds {
+		data, err := os.ReadFile(seed)
+		if err != nil {
+			f.Fatal(err)
+		}
+		headerStart := szSignatureHeaderSize + binary.LittleEndian.Uint64(data[12:20])
+		f.Add(data[szSignatureHeaderSize:headerStart], data[headerStart:])
+	}
+
+	f.Fuzz(func(t *testing.T, packed, header []byte) {
+		defer func(limits ExtractLimits) { Limits = limits }(Limits)
+		Limits = ExtractLimits{MaxTotalBytes: 1 << 20, MaxEntries: 100, MaxRatio: 1000, MaxDepth: 8}
+
+		dir := t.TempDir()
+		src := writeSevenZip(t, dir, packed, header)
+		dest := filepath.Join(dir, "extracted")
+		if err := extractArchive(src, dest, nil); err != nil {
+			if _, statErr := os.Stat(dest); !os.IsNotExist(statErr) {
+				t.Errorf("extraction directory left behind after error %v", err)
+			}
+		}
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			if name := entry.Name(); name != filepath.Base(src) && name != "extracted" {
+				t.Errorf("%s written outside the extraction directory", name)
+			}
+		}
+	})
+}
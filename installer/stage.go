@@ -0,0 +1,183 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// stagingDirSuffix names the staging area, a sibling of the directory holding the map folder, e.g. "Maps.smm-staging".
+// It must lie outside the maps directory, since Skater XL loads every map it finds there.
+const stagingDirSuffix = ".smm-staging"
+
+// stagingPrefix marks SMM's working directories inside the staging area so they can be recognized and cleaned up.
+const stagingPrefix = "install-"
+
+// staging is a working directory in the staging area next to the directory holding the install destination.
+// Keeping it on the same filesystem means the final swap is a pair of renames rather than a copy.
+type staging struct {
+	dir         string // The staging directory itself
+	destination string // The map folder being installed
+	previous    string // Where the existing install is parked during the swap
+}
+
+// stagingArea returns the staging area for map folders in parent.
+func stagingArea(parent string) string {
+	parent = filepath.Clean(parent)
+	return filepath.Join(filepath.Dir(parent), filepath.Base(parent)+stagingDirSuffix)
+}
+
+// newStaging creates a staging directory in the staging area for destination.
+func newStaging(destination string) (*staging, error) {
+	parent := filepath.Dir(destination)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create maps directory '%s': %w", parent, err)
+	}
+	area := stagingArea(parent)
+	if err := os.MkdirAll(area, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create staging area '%s': %w", area, err)
+	}
+	dir, err := os.MkdirTemp(area, stagingPrefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory in '%s': %w", area, err)
+	}
+	return &staging{
+		dir:         dir,
+		destination: destination,
+		previous:    filepath.Join(dir, "previous"),
+	}, nil
+}
+
+// extractDir is where the archive is unpacked.
+func (s *staging) extractDir() string {
+	return filepath.Join(s.dir, "extracted")
+}
+
+// carryOver copies files from the existing install into payload so they survive the swap. Files listed in
+// managed belong to the previous version and are dropped; files already present in payload are replaced
+// by the new version. Everything else was added by the user and is kept.
+func (s *staging) carryOver(payload string, managed []string) error {
+	if _, err := os.Stat(s.destination); os.IsNotExist(err) {
+		return nil
+	}
+
+	managedSet := make(map[string]bool, len(managed))
+	for _, rel := range managed {
+		managedSet[rel] = true
+	}
+
+	existing, err := listFiles(s.destination)
+	if err != nil {
+		return err
+	}
+	for _, rel := range existing {
+		if managedSet[rel] {
+			continue
+		}
+		target := filepath.Join(payload, filepath.FromSlash(rel))
+		if _, err := os.Stat(target); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for '%s': %w", rel, err)
+		}
+		if err := copyFile(filepath.Join(s.destination, filepath.FromSlash(rel)), target); err != nil {
+			return err
+		}
+		Logger.Printf("Keeping user file '%s' from the previous install.", rel)
+	}
+	return nil
+}
+
+// swap moves payload into place. The existing install is parked in the staging directory first and put
+// back if the payload cannot be moved.
+func (s *staging) swap(payload string) error {
+	hadPrevious := false
+	if _, err := os.Stat(s.destination); err == nil {
+		if err := os.Rename(s.destination, s.previous); err != nil {
+			return fmt.Errorf("failed to move existing install aside: %w", err)
+		}
+		hadPrevious = true
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("error checking map destination directory '%s': %w", s.destination, err)
+	}
+
+	if err := os.Rename(payload, s.destination); err != nil {
+		if hadPrevious {
+			if restoreErr := os.Rename(s.previous, s.destination); restoreErr != nil {
+				return fmt.Errorf("failed to move new version into place (%v) and to restore the previous version: %w", err, restoreErr)
+			}
+		}
+		return fmt.Errorf("failed to move new version into place: %w", err)
+	}
+	return nil
+}
+
+// rollback undoes a completed swap, restoring the previous install if there was one.
+func (s *staging) rollback() error {
+	failed := filepath.Join(s.dir, "failed")
+	if err := os.Rename(s.destination, failed); err != nil {
+		return fmt.Errorf("failed to remove new version during rollback: %w", err)
+	}
+	if _, err := os.Stat(s.previous); err == nil {
+		if err := os.Rename(s.previous, s.destination); err != nil {
+			return fmt.Errorf("failed to restore previous version during rollback: %w", err)
+		}
+	}
+	return nil
+}
+
+// commit installs payload as rec. Files the user added to the existing install are carried over, payload is
+// swapped into place and rec is recorded in the manifest. Files listed in managed belong to the version being
+// replaced. The swap happens under the manifest lock after checking that no other map owns the folder, so
+// concurrent installs of maps with the same folder name cannot both swap into it. If the manifest cannot be
+// saved the swap is rolled back.
+func (s *staging) commit(payload string, rec *InstalledMap, managed []string) error {
+	swapped := false
+	err := UpdateManifest(func(manifest *Manifest) error {
+		if owner := manifest.FolderOwner(rec.Folder, rec.MapID); owner != nil {
+			return folderTakenError(rec.Name, rec.Folder, owner)
+		}
+		if err := s.carryOver(payload, managed); err != nil {
+			return fmt.Errorf("failed to keep files from the previous install: %w", err)
+		}
+		if err := s.swap(payload); err != nil {
+			return err
+		}
+		swapped = true
+		manifest.Put(rec)
+		return nil
+	})
+	if err != nil && swapped {
+		if rollbackErr := s.rollback(); rollbackErr != nil {
+			Logger.Printf("Rollback of '%s' failed: %v", rec.Name, rollbackErr)
+			return fmt.Errorf("failed to record install in the manifest (%v) and to roll back: %w", err, rollbackErr)
+		}
+		return fmt.Errorf("failed to record install in the manifest, previous version restored: %w", err)
+	}
+	return err
+}
+
+// backupPrevious saves the version replaced by commit to the backup store. Failures are logged because
+// the install itself has already succeeded.
+func (s *staging) backupPrevious(previous *InstalledMap) {
+	if previous == nil {
+		return
+	}
+	if _, err := os.Stat(s.previous); err != nil {
+		return
+	}
+	if err := saveBackup(previous, s.previous); err != nil {
+		Logger.Printf("Failed to back up '%s' version '%s': %v", previous.Name, previous.Version, err)
+	}
+}
+
+// cleanup removes the staging directory together with any parked previous version.
+func (s *staging) cleanup() {
+	if err := os.RemoveAll(s.dir); err != nil {
+		Logger.Printf("Failed to remove staging directory '%s': %v", s.dir, err)
+		return
+	}
+	// Drop the staging area once no other install is using it; this fails harmlessly while others remain.
+	os.Remove(filepath.Dir(s.dir))
+}
@@ -0,0 +1,223 @@
+#!/usr/bin/env python3
+"""Generates the 7z and RAR fixtures used by archive_test.go.
+
+No archiver is needed: the 7z archives are written following the 7z format specification (7zFormat.txt in
+the LZMA SDK) with Python's lzma module, which provides raw LZMA, LZMA2 and x86 BCJ encoders, and the RAR
+archive follows the RAR 5.0 technical note with stored files. Run it from this directory to regenerate the
+fixtures:
+
+    python3 genfixtures.py
+"""
+
+import lzma
+import random
+import struct
+import zlib
+
+SIGNATURE = b"7z\xbc\xaf\x27\x1c"
+
+K_END = 0x00
+K_HEADER = 0x01
+K_MAIN_STREAMS_INFO = 0x04
+K_FILES_INFO = 0x05
+K_PACK_INFO = 0x06
+K_UNPACK_INFO = 0x07
+K_SUBSTREAMS_INFO = 0x08
+K_SIZE = 0x09
+K_CRC = 0x0A
+K_FOLDER = 0x0B
+K_CODERS_UNPACK_SIZE = 0x0C
+K_NUM_UNPACK_STREAM = 0x0D
+K_EMPTY_STREAM = 0x0E
+K_EMPTY_FILE = 0x0F
+K_NAME = 0x11
+K_WIN_ATTRIBUTES = 0x15
+K_ENCODED_HEADER = 0x17
+
+LZMA_DICT = 1 << 16
+LZMA2_DICT = 1 << 20  # LZMA2 dictionary property 16
+
+FILE_ATTRIBUTE_DIRECTORY = 0x10
+FILE_ATTRIBUTE_ARCHIVE = 0x20
+
+
+def number(v):
+    """Encodes v in the variable length format of 7z headers."""
+    first, mask, i = 0, 0x80, 0
+    while i < 8:
+        if v < 1 << (7 * (i + 1)):
+            first |= v >> (8 * i)
+            break
+        first |= mask
+        mask >>= 1
+        i += 1
+    out = bytes([first & 0xFF])
+    for _ in range(i):
+        out += bytes([v & 0xFF])
+        v >>= 8
+    return out
+
+
+def bits(values):
+    out = bytearray((len(values) + 7) // 8)
+    for i, v in enumerate(values):
+        if v:
+            out[i // 8] |= 0x80 >> (i % 8)
+    return bytes(out)
+
+
+def digests(crcs):
+    return bytes([1]) + b"".join(struct.pack("<I", c) for c in crcs)
+
+
+def lzma_coder(data):
+    lc, lp, pb = 3, 0, 2
+    packed = lzma.compress(data, format=lzma.FORMAT_RAW,
+                           filters=[{"id": lzma.FILTER_LZMA1, "dict_size": LZMA_DICT, "lc": lc, "lp": lp, "pb": pb}])
+    props = bytes([(pb * 5 + lp) * 9 + lc]) + struct.pack("<I", LZMA_DICT)
+    folder = bytes([1, 0x23]) + b"\x03\x01\x01" + number(len(props)) + props
+    return packed, folder, [len(data)]
+
+
+def lzma2_coder(data):
+    packed = lzma.compress(data, format=lzma.FORMAT_RAW, filters=[{"id": lzma.FILTER_LZMA2, "dict_size": LZMA2_DICT}])
+    folder = bytes([1, 0x21, 0x21, 1, 16])
+    return packed, folder, [len(data)]
+
+
+def bcj_coder(data):
+    # Like 7-Zip, list the LZMA2 coder reading the packed stream first and bind the input of the BCJ filter
+    # (in stream 1) to its output (out stream 0).
+    packed = lzma.compress(data, format=lzma.FORMAT_RAW,
+                           filters=[{"id": lzma.FILTER_X86}, {"id": lzma.FILTER_LZMA2, "dict_size": LZMA2_DICT}])
+    folder = bytes([2, 0x21, 0x21, 1, 16, 0x04]) + b"\x03\x03\x01\x03" + number(1) + number(0)
+    return packed, folder, [len(data), len(data)]
+
+
+CODERS = {"lzma": lzma_coder, "lzma2": lzma2_coder, "bcj": bcj_coder}
+
+
+def streams_info(pack_pos, packed, folder, unpack_sizes, substreams=None, folder_crc=None):
+    out = bytes([K_PACK_INFO]) + number(pack_pos) + number(1) + bytes([K_SIZE]) + number(len(packed)) + bytes([K_END])
+    out += bytes([K_UNPACK_INFO, K_FOLDER]) + number(1) + bytes([0]) + folder
+    out += bytes([K_CODERS_UNPACK_SIZE]) + b"".join(number(s) for s in unpack_sizes)
+    if folder_crc is not None:
+        out += bytes([K_CRC]) + digests([folder_crc])
+    out += bytes([K_END])
+    if substreams is not None:
+        out += bytes([K_SUBSTREAMS_INFO, K_NUM_UNPACK_STREAM]) + number(len(substreams))
+        if len(substreams) > 1:
+            out += bytes([K_SIZE]) + b"".join(number(len(d)) for d in substreams[:-1])
+        out += bytes([K_CRC]) + digests([zlib.crc32(d) for d in substreams]) + bytes([K_END])
+    return out + bytes([K_END])
+
+
+def files_info(entries):
+    out = bytes([K_FILES_INFO]) + number(len(entries))
+    empty = [data is None or len(data) == 0 for _, data in entries]
+    if any(empty):
+        prop = bits(empty)
+        out += bytes([K_EMPTY_STREAM]) + number(len(prop)) + prop
+        prop = bits([data is not None for (_, data), e in zip(entries, empty) if e])
+        out += bytes([K_EMPTY_FILE]) + number(len(prop)) + prop
+    names = b"".join(name.encode("utf-16-le") + b"\x00\x00" for name, _ in entries)
+    out += bytes([K_NAME]) + number(len(names) + 1) + bytes([0]) + names
+    attributes = b"".join(struct.pack("<I", FILE_ATTRIBUTE_DIRECTORY if data is None else FILE_ATTRIBUTE_ARCHIVE)
+                          for _, data in entries)
+    out += bytes([K_WIN_ATTRIBUTES]) + number(len(attributes) + 2) + bytes([1, 0]) + attributes
+    return out + bytes([K_END])
+
+
+def write_7z(path, entries, method="lzma", encode_header=False):
+    """Writes entries, a list of (name, data) where data is None for a directory, as a solid 7z archive."""
+    streams = [data for _, data in entries if data]
+    body = b""
+    header = bytes([K_HEADER])
+    if streams:
+        data = b"".join(streams)
+        packed, folder, unpack_sizes = CODERS[method](data)
+        body += packed
+        header += bytes([K_MAIN_STREAMS_INFO]) + streams_info(0, packed, folder, unpack_sizes, substreams=streams)
+    header += files_info(entries) + bytes([K_END])
+
+    if encode_header:
+        packed, folder, unpack_sizes = lzma_coder(header)
+        info = streams_info(len(body), packed, folder, unpack_sizes, folder_crc=zlib.crc32(header))
+        body += packed
+        header = bytes([K_ENCODED_HEADER]) + info
+
+    start = struct.pack("<QQI", len(body), len(header), zlib.crc32(header))
+    with open(path, "wb") as f:
+        f.write(SIGNATURE + b"\x00\x04" + struct.pack("<I", zlib.crc32(start)) + start + body + header)
+
+
+def vint(v):
+    """Encodes v in the variable length format of RAR 5 headers."""
+    out = bytearray()
+    while True:
+        b = v & 0x7F
+        v >>= 7
+        out.append(b | (0x80 if v else 0))
+        if not v:
+            return bytes(out)
+
+
+def rar_block(header_type, fields, flags=0, data=b""):
+    body = vint(header_type) + vint(flags | (0x0002 if data else 0))
+    if data:
+        body += vint(len(data))
+    body += fields
+    block = vint(len(body)) + body
+    return struct.pack("<I", zlib.crc32(block)) + block + data
+
+
+def write_rar(path, entries):
+    """Writes entries, a list of (name, data) where data is None for a directory, as a RAR 5 archive of stored
+    files."""
+    out = b"Rar!\x1a\x07\x01\x00" + rar_block(1, vint(0))
+    for name, data in entries:
+        encoded = name.encode()
+        if data is None:
+            fields = vint(0x0001) + vint(0) + vint(FILE_ATTRIBUTE_DIRECTORY)
+        else:
+            fields = vint(0x0004) + vint(len(data)) + vint(FILE_ATTRIBUTE_ARCHIVE) + struct.pack("<I", zlib.crc32(data))
+        fields += vint(0) + vint(0) + vint(len(encoded)) + encoded  # Stored, created on Windows
+        out += rar_block(2, fields, data=data or b"")
+    out += rar_block(5, vint(0))
+    with open(path, "wb") as f:
+        f.write(out)
+
+
+def x86_code(size):
+    """Returns bytes resembling x86 machine code, full of relative CALL and JMP instructions for BCJ to convert."""
+    rng = random.Random(7)
+    out = bytearray()
+    while len(out) < size:
+        out += bytes([rng.choice([0xE8, 0xE9])]) + struct.pack("<i", rng.randint(-4096, 4096))
+        out += bytes(rng.choice([0x90, 0x55, 0x89, 0xC3]) for _ in range(rng.randint(0, 6)))
+    return bytes(out[:size])
+
+
+def park(text):
+    return (text * 200).encode()
+
+
+if __name__ == "__main__":
+    files = [("Cool Park/cool park", park("UnityFS cool park ")), ("Cool Park/preview.txt", park("preview "))]
+    write_7z("lzma.7z", files, "lzma")
+    write_7z("lzma.7z.zip", files, "lzma")  # Misnamed: a 7z archive with a .zip extension
+    write_7z("lzma2.7z", files, "lzma2")
+    write_7z("bcj.7z", [("Cool Park/plugin.dll", x86_code(16 * 1024))], "bcj")
+    write_7z("encoded-header.7z", files, "lzma2", encode_header=True)
+    write_7z("empty.7z", [
+        ("Cool Park", None),
+        ("Cool Park/empty folder", None),
+        ("Cool Park/cool park", park("UnityFS cool park ")),
+        ("Cool Park/empty.txt", b""),
+    ], "lzma2")
+    write_7z("traversal.7z", [("Cool Park/cool park", park("UnityFS ")), ("../escaped.txt", b"outside")], "lzma2")
+    write_rar("stored.rar", [
+        ("Cool Park", None),
+        ("Cool Park/cool park", park("UnityFS cool park ")),
+        ("Cool Park/preview.txt", park("preview ")),
+    ])
@@ -0,0 +1,84 @@
+package installer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrNotInstalled is returned when a map has no record in the install manifest.
+var ErrNotInstalled = errors.New("map is not installed")
+
+// UninstallMap removes the files recorded for the map matching query (an ID, map name or folder name).
+// Files the user added to the map folder are left alone and only directories left empty are removed.
+func UninstallMap(query string) (*InstalledMap, error) {
+	var removed *InstalledMap
+	err := UpdateManifest(func(manifest *Manifest) error {
+		rec := manifest.Find(query)
+		if rec == nil {
+			return fmt.Errorf("%w: %s", ErrNotInstalled, query)
+		}
+
+		Logger.Printf("Uninstalling '%s' from '%s' (%d files).", rec.Name, rec.Location(), len(rec.Files))
+		if err := removeInstalledFiles(rec.Location(), rec.Files); err != nil {
+			return err
+		}
+
+		manifest.Remove(rec.MapID)
+		removed = rec
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	Logger.Printf("Successfully uninstalled '%s'.", removed.Name)
+	return removed, nil
+}
+
+// removeInstalledFiles deletes the given files below folder, then prunes any directories they leave empty.
+func removeInstalledFiles(folder string, files []string) error {
+	cleanFolder := filepath.Clean(folder)
+	dirs := map[string]bool{cleanFolder: true}
+
+	for _, rel := range files {
+		path := filepath.Join(cleanFolder, filepath.FromSlash(rel))
+		if !strings.HasPrefix(path, cleanFolder+string(os.PathSeparator)) {
+			return fmt.Errorf("refusing to remove file outside map folder: %s", path)
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove file '%s': %w", path, err)
+		}
+		for dir := filepath.Dir(path); dir != cleanFolder && strings.HasPrefix(dir, cleanFolder); dir = filepath.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+
+	// Remove the deepest directories first so parents can become empty.
+	sortedDirs := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		sortedDirs = append(sortedDirs, dir)
+	}
+	sort.Slice(sortedDirs, func(i, j int) bool { return len(sortedDirs[i]) > len(sortedDirs[j]) })
+
+	for _, dir := range sortedDirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("failed to read directory '%s': %w", dir, err)
+		}
+		if len(entries) > 0 {
+			Logger.Printf("Keeping non-empty directory '%s'.", dir)
+			continue
+		}
+		if err := os.Remove(dir); err != nil {
+			return fmt.Errorf("failed to remove empty directory '%s': %w", dir, err)
+		}
+	}
+	return nil
+}
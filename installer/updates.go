@@ -0,0 +1,57 @@
+package installer
+
+import (
+	"github.com/ShawnEdgell/skaterxl-map-manager/api"
+)
+
+// Update describes an installed map whose catalog entry points at a newer modfile.
+type Update struct {
+	Installed *InstalledMap
+	Latest    api.Map
+}
+
+// IsOutdated reports whether the catalog entry for an installed map has a different release than the one installed.
+// Maps whose installed release is unknown are never outdated, since there is nothing to compare.
+func IsOutdated(rec *InstalledMap, latest api.Map) bool {
+	if latest.Modfile.ID == 0 || rec.VersionUnknown {
+		return false
+	}
+	if rec.ModfileID != 0 {
+		return rec.ModfileID != latest.Modfile.ID
+	}
+	// Records without a modfile ID can only be compared by version string.
+	return latest.Modfile.Version != "" && rec.Version != latest.Modfile.Version
+}
+
+// InstalledVersion returns the installed release for display, "unknown" for adopted maps whose release is not known.
+func (r *InstalledMap) InstalledVersion() string {
+	if r.VersionUnknown {
+		return "unknown"
+	}
+	return r.Version
+}
+
+// CheckUpdates compares the installed maps in manifest against the catalog and returns those that are outdated.
+// Pinned maps and maps whose installed release is unknown are never reported.
+func CheckUpdates(manifest *Manifest, catalog []api.Map) []Update {
+	byID := make(map[int]api.Map, len(catalog))
+	for _, m := range catalog {
+		byID[m.ID] = m
+	}
+
+	var updates []Update
+	pinned := 0
+	for _, rec := range manifest.Maps {
+		latest, ok := byID[rec.MapID]
+		if !ok || !IsOutdated(rec, latest) {
+			continue
+		}
+		if rec.Pinned {
+			pinned++
+			continue
+		}
+		updates = append(updates, Update{Installed: rec, Latest: latest})
+	}
+	Logger.Printf("Update check: %d of %d installed maps are outdated, %d more are pinned.", len(updates), len(manifest.Maps), pinned)
+	return updates
+}
@@ -0,0 +1,56 @@
+package installer
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// IntegrityError reports that a downloaded archive does not match the size or hash published in the catalog.
+type IntegrityError struct {
+	MapName  string
+	Check    string // "size" or "md5"
+	Expected string
+	Actual   string
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("downloaded file for '%s' failed the %s check: expected %s, got %s", e.MapName, e.Check, e.Expected, e.Actual)
+}
+
+// verifyArchive checks the archive at path against the expected size and MD5 hash. Empty or zero
+// expectations are skipped, since not every catalog entry carries them.
+func verifyArchive(path, mapName string, expectedSize int64, expectedMD5 string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat downloaded file: %w", err)
+	}
+	if expectedSize > 0 && info.Size() != expectedSize {
+		return &IntegrityError{MapName: mapName, Check: "size", Expected: fmt.Sprintf("%d bytes", expectedSize), Actual: fmt.Sprintf("%d bytes", info.Size())}
+	}
+
+	if expectedMD5 == "" {
+		Logger.Printf("No MD5 hash in catalog for '%s', skipping hash verification.", mapName)
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open downloaded file: %w", err)
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("failed to hash downloaded file: %w", err)
+	}
+	actualMD5 := hex.EncodeToString(h.Sum(nil))
+	if !strings.EqualFold(actualMD5, expectedMD5) {
+		return &IntegrityError{MapName: mapName, Check: "md5", Expected: expectedMD5, Actual: actualMD5}
+	}
+	Logger.Printf("Verified '%s' (%d bytes, md5 %s).", path, info.Size(), actualMD5)
+	return nil
+}
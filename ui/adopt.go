@@ -0,0 +1,164 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/installer"
+)
+
+type adoptScanMsg struct {
+	adoptions []*installer.Adoption
+	unmatched []string
+	err       error
+}
+type adoptDoneMsg struct {
+	recs []*installer.InstalledMap
+	err  error
+}
+
+// adoptScreen holds the matches of a maps folder scan while the user confirms them.
+type adoptScreen struct {
+	adoptions []*installer.Adoption
+	unmatched []string
+	selected  map[int]bool // Indices into adoptions
+	cursor    int
+}
+
+// scanUntrackedCmd looks for manually installed maps in the maps directory.
+func (m Model) scanUntrackedCmd() tea.Cmd {
+	return func() tea.Msg {
+		manifest, err := installer.LoadManifest()
+		if err != nil {
+			return adoptScanMsg{err: err}
+		}
+		adoptions, unmatched, err := installer.ScanUntracked(m.skaterXLMapsDir, m.maps, manifest)
+		return adoptScanMsg{adoptions: adoptions, unmatched: unmatched, err: err}
+	}
+}
+
+func (m Model) adoptCmd(adoptions []*installer.Adoption) tea.Cmd {
+	return func() tea.Msg {
+		recs, err := installer.Adopt(m.skaterXLMapsDir, adoptions)
+		if err != nil {
+			Logger.Printf("Installer: Failed to adopt maps: %v", err)
+		}
+		return adoptDoneMsg{recs: recs, err: err}
+	}
+}
+
+// showAdoptions switches to the adoption screen with every match selected.
+func (m *Model) showAdoptions(msg adoptScanMsg) {
+	if msg.err != nil {
+		m.statusMessage = ErrorMessageStyle.Render(fmt.Sprintf("Could not scan maps folder: %v", msg.err))
+		return
+	}
+	if len(msg.adoptions) == 0 {
+		m.statusMessage = StatusMessageStyle.Render(fmt.Sprintf("No untracked maps matching the catalog (%d unmatched entries).", len(msg.unmatched)))
+		return
+	}
+	screen := &adoptScreen{adoptions: msg.adoptions, unmatched: msg.unmatched, selected: map[int]bool{}}
+	for i := range msg.adoptions {
+		screen.selected[i] = true
+	}
+	m.adopt = screen
+	m.state = stateAdopt
+	m.statusMessage = ""
+}
+
+// updateAdopt handles keys on the adoption screen.
+func (m Model) updateAdopt(msg tea.KeyMsg) (Model, tea.Cmd) {
+	screen := m.adopt
+	switch msg.String() {
+	case "up", "k":
+		if screen.cursor > 0 {
+			screen.cursor--
+		}
+	case "down", "j":
+		if screen.cursor < len(screen.adoptions)-1 {
+			screen.cursor++
+		}
+	case " ":
+		screen.selected[screen.cursor] = !screen.selected[screen.cursor]
+	case "a":
+		all := len(screen.selectedAdoptions()) < len(screen.adoptions)
+		for i := range screen.adoptions {
+			screen.selected[i] = all
+		}
+	case "esc":
+		m.adopt = nil
+		m.state = stateMapList
+		m.statusMessage = "Adoption cancelled."
+	case "enter":
+		selected := screen.selectedAdoptions()
+		m.adopt = nil
+		m.state = stateMapList
+		if len(selected) == 0 {
+			m.statusMessage = "No maps selected for adoption."
+			return m, nil
+		}
+		m.statusMessage = StatusMessageStyle.Render(fmt.Sprintf("Adopting %d maps...", len(selected)))
+		return m, m.adoptCmd(selected)
+	}
+	return m, nil
+}
+
+func (s *adoptScreen) selectedAdoptions() []*installer.Adoption {
+	var selected []*installer.Adoption
+	for i, adoption := range s.adoptions {
+		if s.selected[i] {
+			selected = append(selected, adoption)
+		}
+	}
+	return selected
+}
+
+// adoptView renders the matches found by the scan with their selection state.
+func (m Model) adoptView() string {
+	screen := m.adopt
+	s := strings.Builder{}
+	s.WriteString(QueueHeaderStyle.Render(fmt.Sprintf("Found %d maps installed outside SMM. Selected maps will be managed by SMM.", len(screen.adoptions))))
+	s.WriteString("\n")
+	s.WriteString(HelpStyle.Render("Use ↑/↓ to navigate, Space to toggle, a to toggle all, Enter to adopt the selected maps, Esc to cancel."))
+	s.WriteString("\n\n")
+	for i, adoption := range screen.adoptions {
+		mark := "[ ]"
+		if screen.selected[i] {
+			mark = MarkedItemStyle.Render("[x]")
+		}
+		line := fmt.Sprintf("%s %s → %s %s", mark, filepath.Base(adoption.Path), adoption.Map.Name,
+			SizeTagStyle.Render(fmt.Sprintf("(matched by %s, %s, version %s)", adoption.Match, installer.FormatSize(adoption.Size), adoption.Version())))
+		if i == screen.cursor {
+			s.WriteString(SelectedItemStyle.Render(">" + line))
+		} else {
+			s.WriteString(ListItemStyle.Render(" " + line))
+		}
+		s.WriteString("\n")
+	}
+	if len(screen.unmatched) > 0 {
+		names := make([]string, len(screen.unmatched))
+		for i, path := range screen.unmatched {
+			names[i] = filepath.Base(path)
+		}
+		s.WriteString("\n")
+		s.WriteString(QueuePendingStyle.Render("Not matched: " + strings.Join(names, ", ")))
+	}
+	return s.String()
+}
+
+// adoptedMessage reports how many maps were adopted and how many of them have an unknown version.
+func adoptedMessage(recs []*installer.InstalledMap) string {
+	unknown := 0
+	for _, rec := range recs {
+		if rec.VersionUnknown {
+			unknown++
+		}
+	}
+	if unknown == 0 {
+		return fmt.Sprintf("Adopted %d maps.", len(recs))
+	}
+	return fmt.Sprintf("Adopted %d maps. %d have an unknown version, so update all skips them until they are reinstalled.", len(recs), unknown)
+}
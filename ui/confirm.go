@@ -0,0 +1,30 @@
+package ui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// confirmation is a destructive action on the map list waiting for the user to press y.
+type confirmation struct {
+	action tea.Cmd
+	done   string // Status shown while the confirmed action runs
+}
+
+// askConfirm shows prompt in the status line and runs action once the user presses y.
+func (m *Model) askConfirm(prompt, done string, action tea.Cmd) {
+	m.confirm = &confirmation{action: action, done: done}
+	m.statusMessage = WarningMessageStyle.Render(prompt + " Press y to confirm, any other key to cancel.")
+}
+
+// updateConfirm runs the pending action on y and cancels it on any other key.
+func (m Model) updateConfirm(msg tea.KeyMsg) (Model, tea.Cmd) {
+	pending := m.confirm
+	m.confirm = nil
+	if msg.String() != "y" {
+		Logger.Printf("Update: Confirmation cancelled with '%s'.", msg.String())
+		m.statusMessage = "Cancelled."
+		return m, nil
+	}
+	m.statusMessage = StatusMessageStyle.Render(pending.done)
+	return m, pending.action
+}
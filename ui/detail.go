@@ -0,0 +1,185 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/api"
+	"github.com/ShawnEdgell/skaterxl-map-manager/installer"
+)
+
+// detailChrome is the number of lines the detail pane uses besides the scrolling details.
+const detailChrome = 10
+
+// detailScreen shows everything the catalog knows about one map.
+type detailScreen struct {
+	mapData  api.Map
+	viewport viewport.Model
+}
+
+// openDetail switches to the detail pane for mapData.
+func (m *Model) openDetail(mapData api.Map) {
+	m.detail = &detailScreen{mapData: mapData, viewport: viewport.New(0, 0)}
+	m.state = stateDetail
+	m.statusMessage = ""
+	m.resizeDetail()
+}
+
+// resizeDetail fits the details into the window, rewrapping the text to its width.
+func (m *Model) resizeDetail() {
+	if m.detail == nil {
+		return
+	}
+	width := max(m.width-AppStyle.GetHorizontalPadding()*2, 20)
+	m.detail.viewport.Width = width
+	m.detail.viewport.Height = max(m.height-detailChrome, 3)
+	m.detail.viewport.SetContent(m.detailContent(m.detail.mapData, width))
+}
+
+// updateDetail handles keys on the detail pane. Enter presses the install button; the arrow and page keys
+// scroll.
+func (m Model) updateDetail(msg tea.KeyMsg) (Model, tea.Cmd) {
+	mapData := m.detail.mapData
+	switch msg.String() {
+	case "enter":
+		Logger.Printf("Update: Install button pressed for '%s' (ID: %d).", mapData.Name, mapData.ID)
+		m.detail = nil
+		m.state = stateMapList
+		m.statusMessage = StatusMessageStyle.Render(fmt.Sprintf("Queued %s for install.", mapData.Name))
+		return m, m.enqueue(mapData)
+	case "esc", "backspace":
+		m.detail = nil
+		m.state = stateMapList
+		m.statusMessage = ""
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.detail.viewport, cmd = m.detail.viewport.Update(msg)
+	return m, cmd
+}
+
+// installButtonLabel names what installing the map does now.
+func (m Model) installButtonLabel(mapData api.Map) string {
+	rec := m.manifest.Get(mapData.ID)
+	switch {
+	case rec == nil:
+		return "Install"
+	case installer.IsOutdated(rec, mapData):
+		return fmt.Sprintf("Update to %s", mapData.Modfile.Version)
+	default:
+		return "Reinstall"
+	}
+}
+
+// detailView renders the detail pane: the map name, the scrolling details and the install button.
+func (m Model) detailView() string {
+	screen := m.detail
+	s := strings.Builder{}
+	s.WriteString(TitleStyle.Render(screen.mapData.Name))
+	s.WriteString("\n\n")
+	s.WriteString(screen.viewport.View())
+	s.WriteString("\n")
+	s.WriteString(SizeTagStyle.Render(fmt.Sprintf("%3.f%%", screen.viewport.ScrollPercent()*100)))
+	s.WriteString("\n\n")
+	s.WriteString(ButtonStyle.Render(m.installButtonLabel(screen.mapData)))
+	s.WriteString("\n\n")
+	s.WriteString(HelpStyle.Render("Press Enter to press the button, ↑/↓ or PgUp/PgDn to scroll, Esc to go back."))
+	return s.String()
+}
+
+// detailContent lays out the catalog entry of mapData at the given width.
+func (m Model) detailContent(mapData api.Map, width int) string {
+	s := strings.Builder{}
+	field := func(label, value string) {
+		if value == "" {
+			return
+		}
+		s.WriteString(PromptStyle.Render(fmt.Sprintf("%-13s", label)))
+		s.WriteString(lipgloss.NewStyle().Width(max(width-13, 10)).Render(value))
+		s.WriteString("\n")
+	}
+	section := func(title, body string) {
+		s.WriteString("\n")
+		s.WriteString(QueueHeaderStyle.Render(title))
+		s.WriteString("\n")
+		s.WriteString(lipgloss.NewStyle().Width(width).Padding(0, 1).Render(body))
+		s.WriteString("\n")
+	}
+
+	author := mapData.SubmittedBy.Username
+	if mapData.SubmittedBy.ProfileURL != "" {
+		author += " (" + mapData.SubmittedBy.ProfileURL + ")"
+	}
+	field("Author:", author)
+	field("Version:", fmt.Sprintf("%s (file %d, %s)", mapData.Modfile.Version, mapData.Modfile.ID, mapData.Modfile.Filename))
+	field("Size:", installer.FormatSize(int64(mapData.Modfile.Filesize))+" download")
+	if rec := m.manifest.Get(mapData.ID); rec != nil {
+		installed := fmt.Sprintf("%s in %s (%s)", rec.InstalledVersion(), rec.Location(), installer.FormatSize(rec.DiskUsage()))
+		if installer.IsOutdated(rec, mapData) {
+			installed += ", update available"
+		}
+		if !rec.Enabled() {
+			installed += ", disabled"
+		}
+		if rec.Pinned {
+			installed += ", pinned"
+		}
+		field("Installed:", installed)
+	}
+	field("Downloads:", fmt.Sprint(mapData.Stats.DownloadsTotal))
+	field("Subscribers:", fmt.Sprint(mapData.Stats.SubscribersTotal))
+	rating := mapData.Stats.RatingsDisplayText
+	if votes := mapData.Stats.RatingsPositive + mapData.Stats.RatingsNegative; votes > 0 {
+		rating = strings.TrimSpace(fmt.Sprintf("%s (%d up, %d down)", rating, mapData.Stats.RatingsPositive, mapData.Stats.RatingsNegative))
+	}
+	field("Rating:", rating)
+	field("Added:", formatDate(mapData.DateAdded))
+	field("Updated:", formatDate(mapData.DateUpdated))
+	field("Live:", formatDate(mapData.DateLive))
+	var tags []string
+	for _, tag := range mapData.Tags {
+		tags = append(tags, tag.Name)
+	}
+	field("Tags:", strings.Join(tags, ", "))
+	field("Page:", mapData.ProfileURL)
+
+	description := strings.TrimSpace(mapData.DescriptionPlaintext)
+	if description == "" {
+		description = strings.TrimSpace(mapData.Summary)
+	}
+	if description == "" {
+		description = "No description."
+	}
+	section("Description", description)
+	if changelog := strings.TrimSpace(mapData.Modfile.Changelog); changelog != "" {
+		section(fmt.Sprintf("Changelog for %s", mapData.Modfile.Version), changelog)
+	}
+
+	var images []string
+	if mapData.Logo.Original != "" {
+		images = append(images, mapData.Logo.Original)
+	}
+	for _, image := range mapData.Media.Images {
+		if image.Original != "" {
+			images = append(images, image.Original)
+		}
+	}
+	if len(images) == 0 {
+		images = append(images, "No images.")
+	}
+	section("Images", strings.Join(images, "\n"))
+	return s.String()
+}
+
+// formatDate renders a catalog timestamp as a date, or nothing when the catalog has none.
+func formatDate(ts int64) string {
+	if ts == 0 {
+		return ""
+	}
+	return time.Unix(ts, 0).Format("2006-01-02")
+}
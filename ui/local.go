@@ -0,0 +1,92 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/filepicker"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/installer"
+)
+
+// filePickerChrome is the number of lines the file picker screen uses besides the file list.
+const filePickerChrome = 8
+
+// openFilePicker switches to the file picker, starting in the Downloads folder when there is one.
+func (m *Model) openFilePicker() tea.Cmd {
+	picker := filepicker.New()
+	picker.DirAllowed = false
+	picker.ShowPermissions = false
+	picker.AutoHeight = false
+	picker.Height = max(m.height-filePickerChrome, 5)
+	// Esc cancels the picker instead of going up a folder.
+	picker.KeyMap.Back = key.NewBinding(key.WithKeys("h", "backspace", "left"), key.WithHelp("h", "back"))
+	// Archives are recognised by their contents, so every file can be picked.
+	picker.CurrentDirectory = startDirectory()
+
+	m.filePicker = picker
+	m.state = stateFilePicker
+	m.statusMessage = ""
+	return picker.Init()
+}
+
+// startDirectory returns the user's Downloads folder, or their home folder when there is none.
+func startDirectory() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "."
+	}
+	downloads := filepath.Join(home, "Downloads")
+	if info, err := os.Stat(downloads); err == nil && info.IsDir() {
+		return downloads
+	}
+	return home
+}
+
+// updateFilePicker handles keys on the file picker. Enter on an archive installs it, i installs the folder
+// being browsed.
+func (m Model) updateFilePicker(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.state = stateMapList
+		m.statusMessage = "Install from file cancelled."
+		return m, nil
+	case "i":
+		return m.installLocal(m.filePicker.CurrentDirectory)
+	}
+
+	var cmd tea.Cmd
+	m.filePicker, cmd = m.filePicker.Update(msg)
+	if ok, path := m.filePicker.DidSelectFile(msg); ok {
+		return m.installLocal(path)
+	}
+	return m, cmd
+}
+
+// installLocal queues the archive or folder at path for install and returns to the map list.
+func (m Model) installLocal(path string) (Model, tea.Cmd) {
+	m.state = stateMapList
+	mapData, err := installer.LocalMap(path, "")
+	if err != nil {
+		m.statusMessage = ErrorMessageStyle.Render(err.Error())
+		return m, nil
+	}
+	Logger.Printf("Update: Queueing local install of '%s' as '%s' (ID: %d).", path, mapData.Name, mapData.ID)
+	m.statusMessage = StatusMessageStyle.Render(fmt.Sprintf("Queued %s from %s for install.", mapData.Name, filepath.Base(path)))
+	return m, m.enqueueLocal(mapData, path)
+}
+
+// filePickerView renders the file picker with the folder being browsed.
+func (m Model) filePickerView() string {
+	s := strings.Builder{}
+	s.WriteString(QueueHeaderStyle.Render("Install a map from an archive or folder: " + m.filePicker.CurrentDirectory))
+	s.WriteString("\n")
+	s.WriteString(HelpStyle.Render("Use ↑/↓ to navigate, →/l to open a folder, ←/h to go up, Enter to install an archive, i to install this folder, Esc to cancel."))
+	s.WriteString("\n\n")
+	s.WriteString(m.filePicker.View())
+	return s.String()
+}
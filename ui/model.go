@@ -1,15 +1,17 @@
 package ui
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
-	"time"
 
+	"github.com/charmbracelet/bubbles/filepicker"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -28,7 +30,10 @@ const (
 	stateLoadingMaps appState = iota
 	statePromptDir
 	stateMapList
-	stateInstalling
+	stateAdopt
+	stateFilePicker
+	stateProfiles
+	stateDetail
 	stateError
 	stateExiting
 )
@@ -36,15 +41,38 @@ const (
 type errMsg struct{ err error }
 func (e errMsg) Error() string { return e.err.Error() }
 
-type mapsFetchedMsg []api.Map
-type installProgressMsg installer.ProgressMsg
-type installDoneMsg struct {
+type mapsFetchedMsg struct{ catalog *api.Catalog }
+type uninstallDoneMsg struct {
 	mapName string
 	err     error
 }
+type restoreDoneMsg struct {
+	mapName string
+	rec     *installer.InstalledMap
+	err     error
+}
+type toggleDoneMsg struct {
+	mapName string
+	rec     *installer.InstalledMap
+	err     error
+}
+type pinDoneMsg struct {
+	mapName string
+	rec     *installer.InstalledMap
+	err     error
+}
 
 type Item struct {
-	mapData api.Map
+	mapData   api.Map
+	installed bool
+	outdated  bool
+	marked    bool
+	disabled  bool             // Installed but moved out of the maps directory
+	pinned    bool             // Kept at the installed version; update all skips it
+	unknown   bool             // Adopted without knowing the installed version
+	local     bool             // Installed from a local file, so the catalog does not list it
+	match     *api.SearchMatch // How the map matched the search, nil without one
+	size      int64            // Disk usage when installed, download size otherwise
 }
 
 func (i Item) FilterValue() string { return i.mapData.Name }
@@ -65,7 +93,35 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, listItem list
 		return
 	}
 
-	str := fmt.Sprintf("%d. %s", index+1, i.Title())
+	mark := "  "
+	if i.marked {
+		mark = MarkedItemStyle.Render("+ ")
+	}
+	str := fmt.Sprintf("%s%d. %s", mark, index+1, i.Title())
+	if i.match != nil {
+		str = fmt.Sprintf("%s%d. %s", mark, index+1, highlight(i.Title(), i.match.Name))
+		if hints := matchHints(i.mapData, i.match); hints != "" {
+			str += " " + hints
+		}
+	}
+	if i.outdated {
+		str += " " + UpdateTagStyle.Render("[update available]")
+	} else if i.unknown {
+		str += " " + InstalledTagStyle.Render("[installed, version unknown]")
+	} else if i.local {
+		str += " " + InstalledTagStyle.Render("[installed, local file]")
+	} else if i.installed {
+		str += " " + InstalledTagStyle.Render("[installed]")
+	}
+	if i.disabled {
+		str += " " + DisabledTagStyle.Render("[disabled]")
+	}
+	if i.pinned {
+		str += " " + PinnedTagStyle.Render("[pinned]")
+	}
+	if i.size > 0 {
+		str += " " + SizeTagStyle.Render(installer.FormatSize(i.size))
+	}
 	var renderedStr string
 
 	width := m.Width() - SelectedItemStyle.GetPaddingLeft() - SelectedItemStyle.GetPaddingRight()
@@ -80,9 +136,6 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, listItem list
 	fmt.Fprint(w, renderedStr)
 }
 
-
-type progressTickMsg struct{}
-
 type Model struct {
 	state           appState
 	maps            []api.Map
@@ -92,10 +145,29 @@ type Model struct {
 	statusMessage   string
 	skaterXLMapsDir string
 	config          *config.Config
+	client          *api.Client
+	catalog         *api.Catalog
 	sortField       string
 	sortAscending   bool
-	progressChan    chan installer.ProgressMsg
-	doneChan        chan installDoneMsg
+	manifest        *installer.Manifest
+	outdated        map[int]bool
+	installed       map[int]installedState // Cached by reloadInstalled
+	localMaps       []api.Map              // Maps installed from local files, cached by reloadInstalled
+	marked          map[int]bool
+	diskUsage       int64 // Combined disk usage of installed maps
+	adopt           *adoptScreen
+	filePicker      filepicker.Model
+	profiles        *profileScreen
+	search          textinput.Model
+	searching       bool          // The search input has focus
+	query           *api.Query    // Search filtering the list, nil to show every map
+	tagPanel        *tagPanel     // Open tag filter panel, nil while closed
+	confirm         *confirmation // Action waiting for y, nil when nothing is asked
+	detail          *detailScreen
+	queue           *installer.Queue
+	queueEntries    []*queueEntry
+	width           int
+	height          int
 }
 
 const (
@@ -104,7 +176,7 @@ const (
 	sortByRecent      = "recent"
 )
 
-func NewModel(cfg *config.Config) Model {
+func NewModel(cfg *config.Config, client *api.Client) Model {
 	ti := textinput.New()
 	ti.Focus()
 	ti.CharLimit = 250
@@ -148,20 +220,145 @@ func NewModel(cfg *config.Config) Model {
 	m.Styles.FilterCursor = lipgloss.NewStyle().Foreground(ColorAccent)
 	m.Styles.StatusBar = lipgloss.NewStyle().Foreground(ColorDarkGray)
 	m.SetShowHelp(true)
-
+	// u and b uninstall and restore maps, so they cannot page up as they do by default.
+	m.KeyMap.PrevPage.SetKeys("left", "h", "pgup")
 
 	return Model{
 		state:         stateLoadingMaps,
 		textInput:     ti,
 		mapList:       m,
+		search:        newSearchInput(),
 		config:        cfg,
+		client:        client,
 		sortField:     sortByRecent,
 		sortAscending: false,
-		progressChan:  make(chan installer.ProgressMsg),
-		doneChan:      make(chan installDoneMsg),
+		manifest:      &installer.Manifest{},
+		outdated:      map[int]bool{},
+		installed:     map[int]installedState{},
+		marked:        map[int]bool{},
 	}
 }
 
+// installedState is what the map list shows about an installed map, cached by reloadInstalled.
+type installedState struct {
+	size     int64 // Disk usage
+	disabled bool
+	pinned   bool
+	unknown  bool // Adopted without knowing the installed version
+}
+
+// reloadInstalled reloads the install manifest, recomputes which maps are outdated and caches the state of
+// the installed maps, then rebuilds the list items. It reads the disk, so it only runs when installed maps
+// or the catalog change.
+func (m *Model) reloadInstalled() {
+	manifest, err := installer.LoadManifest()
+	if err != nil {
+		Logger.Printf("reloadInstalled: failed to load manifest: %v", err)
+	} else {
+		m.manifest = manifest
+	}
+
+	m.outdated = map[int]bool{}
+	for _, update := range installer.CheckUpdates(m.manifest, m.maps) {
+		m.outdated[update.Latest.ID] = true
+	}
+
+	m.installed = make(map[int]installedState, len(m.manifest.Maps))
+	m.localMaps = nil
+	m.diskUsage = 0
+	for _, rec := range m.manifest.Maps {
+		if rec.Source == installer.SourceLocal {
+			// The catalog does not list these, so they are added to the list from their records.
+			m.localMaps = append(m.localMaps, api.Map{ID: rec.MapID, Name: rec.Name})
+		}
+		state := installedState{
+			size:     rec.DiskUsage(),
+			disabled: !rec.Enabled(),
+			pinned:   rec.Pinned,
+			unknown:  rec.VersionUnknown,
+		}
+		m.installed[rec.MapID] = state
+		m.diskUsage += state.size
+	}
+	m.filterItems()
+}
+
+// filterItems rebuilds the list items from the cached installed state, keeping only the maps passing the tag
+// filter and matching the search. It runs on every search keystroke, so it must not touch the disk.
+func (m *Model) filterItems() {
+	// The tag filter and the search keep the order of m.maps, so they combine with the sort modes. Maps
+	// installed from local files follow the catalog.
+	maps := m.tagFilter().Filter(append(append([]api.Map(nil), m.maps...), m.localMaps...))
+	results := make([]api.SearchResult, len(maps))
+	for i, mapData := range maps {
+		results[i] = api.SearchResult{Map: mapData}
+	}
+	if m.query != nil {
+		results = api.Search(maps, m.query)
+	}
+
+	items := make([]list.Item, len(results))
+	for i, result := range results {
+		mapData := result.Map
+		state, installed := m.installed[mapData.ID]
+		size := state.size
+		if !installed {
+			size = int64(mapData.Modfile.Filesize)
+		}
+		items[i] = Item{
+			mapData:   mapData,
+			installed: installed,
+			outdated:  m.outdated[mapData.ID],
+			marked:    m.marked[mapData.ID],
+			disabled:  state.disabled,
+			pinned:    state.pinned,
+			unknown:   state.unknown,
+			local:     mapData.ID < 0,
+			match:     result.Match,
+			size:      size,
+		}
+	}
+	m.mapList.SetItems(items)
+}
+
+// resizeList fits the map list into the window, leaving room for the queue panel.
+func (m *Model) resizeList() {
+	hPadding := AppStyle.GetHorizontalPadding()
+	vPadding := AppStyle.GetVerticalPadding()
+	totalNonListHeight := lipgloss.Height(TitleStyle.Render("A")) +
+		lipgloss.Height(HelpStyle.Render("A")) +
+		lipgloss.Height(StatusMessageStyle.Render("A")) +
+		vPadding*2 +
+		m.queuePanelHeight()
+	width := m.width - hPadding*2
+	if m.tagPanel != nil {
+		width -= tagPanelWidth
+	}
+	m.mapList.SetSize(width, m.height-totalNonListHeight)
+}
+
+// markedMaps returns the marked maps in list order.
+func (m *Model) markedMaps() []api.Map {
+	var maps []api.Map
+	for _, mapData := range m.maps {
+		if m.marked[mapData.ID] {
+			maps = append(maps, mapData)
+		}
+	}
+	return maps
+}
+
+// outdatedMaps returns the catalog entries of every installed map with an available update.
+func (m *Model) outdatedMaps() []api.Map {
+	var maps []api.Map
+	for _, mapData := range m.maps {
+		if m.outdated[mapData.ID] {
+			maps = append(maps, mapData)
+		}
+	}
+	return maps
+}
+
 func (m *Model) sortMaps() {
 	sort.Slice(m.maps, func(i, j int) bool {
 		switch m.sortField {
@@ -203,39 +400,30 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		Logger.Printf("Update: WindowSizeMsg received: %+v", msg)
-		hPadding := AppStyle.GetHorizontalPadding()
-		vPadding := AppStyle.GetVerticalPadding()
-		totalNonListHeight := lipgloss.Height(TitleStyle.Render("A")) +
-			lipgloss.Height(HelpStyle.Render("A")) +
-			lipgloss.Height(StatusMessageStyle.Render("A")) +
-			vPadding*2
-		m.mapList.SetSize(msg.Width-hPadding*2, msg.Height-totalNonListHeight)
-		m.textInput.Width = msg.Width - hPadding*2 - 4
+		m.width, m.height = msg.Width, msg.Height
+		m.resizeList()
+		m.resizeDetail()
+		m.textInput.Width = msg.Width - AppStyle.GetHorizontalPadding()*2 - 4
+		if m.state == stateFilePicker {
+			m.filePicker.Height = max(msg.Height-filePickerChrome, 5)
+			m.filePicker, cmd = m.filePicker.Update(msg)
+			cmds = append(cmds, cmd)
+		}
 
 	case mapsFetchedMsg:
-		Logger.Printf("Update: mapsFetchedMsg received. Map count: %d", len(msg))
-
-		var filteredMaps []api.Map
-		for _, mapData := range msg {
-			lowerCaseName := strings.ToLower(mapData.Name)
-			if !strings.Contains(lowerCaseName, "ps4") &&
-				!strings.Contains(lowerCaseName, "playstation") &&
-				!strings.Contains(lowerCaseName, "xbox") {
-				filteredMaps = append(filteredMaps, mapData)
-			}
-		}
-		m.maps = filteredMaps
-		m.sortMaps()
+		Logger.Printf("Update: mapsFetchedMsg received. Map count: %d, from cache: %v, offline: %v", len(msg.catalog.Maps), msg.catalog.FromCache, msg.catalog.Offline)
 
-		items := make([]list.Item, len(m.maps))
-		for i, mapData := range m.maps {
-			items[i] = Item{mapData: mapData}
-		}
-		m.mapList.SetItems(items)
+		m.catalog = msg.catalog
+		m.maps = api.FilterConsoleMaps(msg.catalog.Maps)
+		m.sortMaps()
+		m.reloadInstalled()
 
 		if m.config.SkaterXLMapsDir != "" {
 			m.skaterXLMapsDir = m.config.SkaterXLMapsDir
 			m.statusMessage = fmt.Sprintf("Using saved maps directory.")
+			if len(m.outdated) > 0 {
+				m.statusMessage = fmt.Sprintf("Using saved maps directory. %d installed maps have updates (press U to update all).", len(m.outdated))
+			}
 			m.state = stateMapList
 			Logger.Printf("Update: Changed state to stateMapList (saved dir). Status: %s", m.statusMessage)
 		} else {
@@ -249,26 +437,84 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.currentError = msg.err
 		m.state = stateError
 
-	case installProgressMsg:
-		Logger.Printf("Update: installProgressMsg received: %+v", msg)
-		progress := float64(msg.Current) / float64(msg.Total) * 100
-		m.statusMessage = StatusMessageStyle.Render(fmt.Sprintf("%s Progress: %.2f%% (%d/%d bytes)", strings.Title(msg.Type), progress, msg.Current, msg.Total))
-		cmds = append(cmds, m.progressTickCmd())
+	case queueEventMsg:
+		Logger.Printf("Update: queueEventMsg received: %s %s", msg.Map.Name, msg.Status)
+		m.setQueueEntry(installer.QueueEvent(msg))
+		switch msg.Status {
+		case installer.StatusDone:
+			m.reloadInstalled()
+			m.statusMessage = StatusMessageStyle.Render(fmt.Sprintf("Successfully installed %s!", msg.Map.Name))
+		case installer.StatusFailed:
+			m.statusMessage = ErrorMessageStyle.Render(fmt.Sprintf("Failed to install %s: %s", msg.Map.Name, describeInstallError(msg.Err)))
+		}
+		m.resizeList()
+		cmds = append(cmds, listenQueueCmd(m.queue))
 
-	case progressTickMsg:
-		// This message is sent by the ticker, re-issue the command to keep receiving updates
-		cmds = append(cmds, m.progressTickCmd())
+	case queueClosedMsg:
+		Logger.Printf("Update: install queue closed.")
+		m.queue = nil
 
-	case installDoneMsg:
-		Logger.Printf("Update: installDoneMsg received: %+v", msg)
+	case uninstallDoneMsg:
+		Logger.Printf("Update: uninstallDoneMsg received: %+v", msg)
+		m.reloadInstalled()
 		if msg.err != nil {
-			m.currentError = msg.err
-			m.state = stateError
-			Logger.Printf("Update: Install failed, transitioned to stateError: %v", msg.err)
+			m.statusMessage = ErrorMessageStyle.Render(fmt.Sprintf("Could not uninstall %s: %v", msg.mapName, msg.err))
 		} else {
-			m.statusMessage = StatusMessageStyle.Render(fmt.Sprintf("Successfully installed %s!", msg.mapName))
-			m.state = stateMapList
-			Logger.Printf("Update: Install successful, transitioned to stateMapList.")
+			m.statusMessage = StatusMessageStyle.Render(fmt.Sprintf("Uninstalled %s.", msg.mapName))
+		}
+
+	case restoreDoneMsg:
+		Logger.Printf("Update: restoreDoneMsg received: %+v", msg)
+		m.reloadInstalled()
+		if msg.err != nil {
+			m.statusMessage = ErrorMessageStyle.Render(fmt.Sprintf("Could not restore %s: %v", msg.mapName, msg.err))
+		} else {
+			m.statusMessage = StatusMessageStyle.Render(fmt.Sprintf("Restored %s to version %s. Press b and y again to undo.", msg.mapName, msg.rec.InstalledVersion()))
+		}
+
+	case toggleDoneMsg:
+		Logger.Printf("Update: toggleDoneMsg received: %+v", msg)
+		m.reloadInstalled()
+		switch {
+		case msg.err != nil:
+			m.statusMessage = ErrorMessageStyle.Render(fmt.Sprintf("Could not enable or disable %s: %v", msg.mapName, msg.err))
+		case msg.rec.Enabled():
+			m.statusMessage = StatusMessageStyle.Render(fmt.Sprintf("Enabled %s. The game loads it again.", msg.mapName))
+		default:
+			m.statusMessage = StatusMessageStyle.Render(fmt.Sprintf("Disabled %s. It stays installed in %s but the game skips it.", msg.mapName, msg.rec.Location()))
+		}
+
+	case pinDoneMsg:
+		Logger.Printf("Update: pinDoneMsg received: %+v", msg)
+		m.reloadInstalled()
+		switch {
+		case msg.err != nil:
+			m.statusMessage = ErrorMessageStyle.Render(fmt.Sprintf("Could not pin or unpin %s: %v", msg.mapName, msg.err))
+		case msg.rec.Pinned:
+			m.statusMessage = StatusMessageStyle.Render(fmt.Sprintf("Pinned %s to version %s. Update all skips it.", msg.mapName, msg.rec.InstalledVersion()))
+		default:
+			m.statusMessage = StatusMessageStyle.Render(fmt.Sprintf("Unpinned %s. Update all includes it again.", msg.mapName))
+		}
+
+	case profilesLoadedMsg:
+		Logger.Printf("Update: profilesLoadedMsg received: %d profiles, err %v", len(msg.profiles), msg.err)
+		m.showProfiles(msg)
+
+	case profileAppliedMsg:
+		Logger.Printf("Update: profileAppliedMsg received for '%s', err %v", msg.profile.Name, msg.err)
+		cmds = append(cmds, m.finishApply(msg))
+
+	case adoptScanMsg:
+		Logger.Printf("Update: adoptScanMsg received: %d matches, %d unmatched, err %v", len(msg.adoptions), len(msg.unmatched), msg.err)
+		m.showAdoptions(msg)
+
+	case adoptDoneMsg:
+		Logger.Printf("Update: adoptDoneMsg received: %d adopted, err %v", len(msg.recs), msg.err)
+		m.reloadInstalled()
+		if msg.err != nil {
+			m.statusMessage = ErrorMessageStyle.Render(fmt.Sprintf("Adopted %d maps, but some failed: %v", len(msg.recs), msg.err))
+		} else {
+			m.statusMessage = StatusMessageStyle.Render(adoptedMessage(msg.recs))
 		}
 
 	case tea.KeyMsg:
@@ -278,7 +524,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case msg.Type == tea.KeyCtrlC:
 			m.state = stateExiting
 			return m, tea.Quit
-		case msg.String() == "q":
+		case msg.String() == "q" && !(m.state == stateProfiles && m.profiles.naming) && !(m.state == stateMapList && m.searching):
 			m.state = stateExiting
 			return m, tea.Quit
 		}
@@ -319,17 +565,178 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 		case stateMapList:
+			if m.searching {
+				m, cmd = m.updateSearch(msg)
+				cmds = append(cmds, cmd)
+				break
+			}
+			if m.tagPanel != nil {
+				m, cmd = m.updateTagPanel(msg)
+				cmds = append(cmds, cmd)
+				break
+			}
+			if m.confirm != nil {
+				m, cmd = m.updateConfirm(msg)
+				cmds = append(cmds, cmd)
+				break
+			}
 			switch key := msg.String(); key {
 			case "enter":
+				if marked := m.markedMaps(); len(marked) > 0 {
+					Logger.Printf("Update: Queueing %d marked maps.", len(marked))
+					m.statusMessage = StatusMessageStyle.Render(fmt.Sprintf("Queued %d maps for install.", len(marked)))
+					m.marked = map[int]bool{}
+					m.filterItems()
+					cmds = append(cmds, m.enqueue(marked...))
+					break
+				}
 				selectedItem, ok := m.mapList.SelectedItem().(Item)
 				if !ok {
 					m.statusMessage = ErrorMessageStyle.Render("No map selected. Press up/down to select a map.")
 					return m, nil
 				}
+				if selectedItem.local {
+					m.statusMessage = ErrorMessageStyle.Render(fmt.Sprintf("%s was installed from a local file. Press F to install a new version.", selectedItem.mapData.Name))
+					return m, nil
+				}
 				Logger.Printf("Update: Selected map '%s' (ID: %d). Preparing to install.", selectedItem.mapData.Name, selectedItem.mapData.ID)
-				m.statusMessage = StatusMessageStyle.Render(fmt.Sprintf("You selected: %s. Initiating install...", selectedItem.mapData.Name))
-				m.state = stateInstalling
-				cmds = append(cmds, m.installMapCmd(selectedItem.mapData, m.skaterXLMapsDir))
+				m.statusMessage = StatusMessageStyle.Render(fmt.Sprintf("Queued %s for install.", selectedItem.mapData.Name))
+				cmds = append(cmds, m.enqueue(selectedItem.mapData))
+
+			case " ":
+				selectedItem, ok := m.mapList.SelectedItem().(Item)
+				if !ok {
+					return m, nil
+				}
+				if selectedItem.local {
+					m.statusMessage = ErrorMessageStyle.Render(fmt.Sprintf("%s was installed from a local file and cannot be queued.", selectedItem.mapData.Name))
+					return m, nil
+				}
+				if m.marked[selectedItem.mapData.ID] {
+					delete(m.marked, selectedItem.mapData.ID)
+				} else {
+					m.marked[selectedItem.mapData.ID] = true
+				}
+				selectedItem.marked = m.marked[selectedItem.mapData.ID]
+				m.mapList.SetItem(m.mapList.Index(), selectedItem)
+				m.statusMessage = fmt.Sprintf("%d maps marked. Press Enter to queue them.", len(m.marked))
+
+			case "c":
+				m.clearFinished()
+
+			case "r":
+				cmd, retried := m.retryFailed()
+				if retried > 0 {
+					m.statusMessage = StatusMessageStyle.Render(fmt.Sprintf("Retrying %d failed installs.", retried))
+				}
+				cmds = append(cmds, cmd)
+
+			case "U":
+				outdated := m.outdatedMaps()
+				if len(outdated) == 0 {
+					m.statusMessage = StatusMessageStyle.Render("All installed maps are up to date.")
+					return m, nil
+				}
+				Logger.Printf("Update: Updating %d outdated maps.", len(outdated))
+				m.statusMessage = StatusMessageStyle.Render(fmt.Sprintf("Queued %d outdated maps for update.", len(outdated)))
+				cmds = append(cmds, m.enqueue(outdated...))
+
+			case "u":
+				selectedItem, ok := m.mapList.SelectedItem().(Item)
+				if !ok {
+					m.statusMessage = ErrorMessageStyle.Render("No map selected. Press up/down to select a map.")
+					return m, nil
+				}
+				if !selectedItem.installed {
+					m.statusMessage = ErrorMessageStyle.Render(fmt.Sprintf("%s is not installed.", selectedItem.mapData.Name))
+					return m, nil
+				}
+				Logger.Printf("Update: Asking to uninstall map '%s' (ID: %d).", selectedItem.mapData.Name, selectedItem.mapData.ID)
+				m.askConfirm(fmt.Sprintf("Uninstall %s and delete its files?", selectedItem.mapData.Name),
+					fmt.Sprintf("Uninstalling %s...", selectedItem.mapData.Name), m.uninstallMapCmd(selectedItem.mapData))
+
+			case "b":
+				selectedItem, ok := m.mapList.SelectedItem().(Item)
+				if !ok {
+					m.statusMessage = ErrorMessageStyle.Render("No map selected. Press up/down to select a map.")
+					return m, nil
+				}
+				if !selectedItem.installed {
+					m.statusMessage = ErrorMessageStyle.Render(fmt.Sprintf("%s is not installed.", selectedItem.mapData.Name))
+					return m, nil
+				}
+				Logger.Printf("Update: Asking to restore previous version of map '%s' (ID: %d).", selectedItem.mapData.Name, selectedItem.mapData.ID)
+				m.askConfirm(fmt.Sprintf("Replace the installed version of %s with its previous version?", selectedItem.mapData.Name),
+					fmt.Sprintf("Restoring previous version of %s...", selectedItem.mapData.Name), m.restoreMapCmd(selectedItem.mapData))
+
+			case "e":
+				selectedItem, ok := m.mapList.SelectedItem().(Item)
+				if !ok {
+					m.statusMessage = ErrorMessageStyle.Render("No map selected. Press up/down to select a map.")
+					return m, nil
+				}
+				if !selectedItem.installed {
+					m.statusMessage = ErrorMessageStyle.Render(fmt.Sprintf("%s is not installed.", selectedItem.mapData.Name))
+					return m, nil
+				}
+				Logger.Printf("Update: Toggling map '%s' (ID: %d), currently disabled: %v.", selectedItem.mapData.Name, selectedItem.mapData.ID, selectedItem.disabled)
+				cmds = append(cmds, m.toggleMapCmd(selectedItem.mapData, selectedItem.disabled))
+
+			case "p":
+				selectedItem, ok := m.mapList.SelectedItem().(Item)
+				if !ok {
+					m.statusMessage = ErrorMessageStyle.Render("No map selected. Press up/down to select a map.")
+					return m, nil
+				}
+				if !selectedItem.installed {
+					m.statusMessage = ErrorMessageStyle.Render(fmt.Sprintf("%s is not installed.", selectedItem.mapData.Name))
+					return m, nil
+				}
+				Logger.Printf("Update: Toggling pin of map '%s' (ID: %d), currently pinned: %v.", selectedItem.mapData.Name, selectedItem.mapData.ID, selectedItem.pinned)
+				cmds = append(cmds, m.pinMapCmd(selectedItem.mapData, !selectedItem.pinned))
+
+			case "A":
+				Logger.Printf("Update: Scanning '%s' for maps installed outside SMM.", m.skaterXLMapsDir)
+				m.statusMessage = StatusMessageStyle.Render("Scanning maps folder for maps installed by hand...")
+				cmds = append(cmds, m.scanUntrackedCmd())
+
+			case "P":
+				Logger.Printf("Update: Opening profiles.")
+				cmds = append(cmds, m.openProfiles())
+
+			case "F":
+				Logger.Printf("Update: Opening file picker for a local install.")
+				cmds = append(cmds, m.openFilePicker())
+
+			case "/":
+				Logger.Printf("Update: Starting search.")
+				cmds = append(cmds, m.startSearch())
+
+			case "i":
+				selectedItem, ok := m.mapList.SelectedItem().(Item)
+				if !ok {
+					m.statusMessage = ErrorMessageStyle.Render("No map selected. Press up/down to select a map.")
+					return m, nil
+				}
+				if selectedItem.local {
+					m.statusMessage = ErrorMessageStyle.Render(fmt.Sprintf("%s was installed from a local file and has no catalog page.", selectedItem.mapData.Name))
+					return m, nil
+				}
+				Logger.Printf("Update: Opening details of map '%s' (ID: %d).", selectedItem.mapData.Name, selectedItem.mapData.ID)
+				m.openDetail(selectedItem.mapData)
+
+			case "t":
+				Logger.Printf("Update: Opening tag panel.")
+				m.openTagPanel()
+
+			case "esc":
+				if m.search.Value() != "" {
+					Logger.Printf("Update: Clearing search '%s'.", m.search.Value())
+					m.clearSearch()
+					break
+				}
+				m.mapList, cmd = m.mapList.Update(msg)
+				cmds = append(cmds, cmd)
 
 			case "1":
 				switch m.sortField {
@@ -344,11 +751,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.sortAscending = false
 				}
 				m.sortMaps()
-				items := make([]list.Item, len(m.maps))
-				for i, mapData := range m.maps {
-					items[i] = Item{mapData: mapData}
-				}
-				m.mapList.SetItems(items)
+				m.filterItems()
 				m.mapList.Paginator.Page = 0
 				m.mapList.Select(0)
 				m.statusMessage = fmt.Sprintf("Sorted by %s (%s).", m.sortField, m.sortOrderString())
@@ -356,11 +759,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "2":
 				m.sortAscending = !m.sortAscending
 				m.sortMaps()
-				items := make([]list.Item, len(m.maps))
-				for i, mapData := range m.maps {
-					items[i] = Item{mapData: mapData}
-				}
-				m.mapList.SetItems(items)
+				m.filterItems()
 				m.mapList.Paginator.Page = 0
 				m.mapList.Select(0)
 				m.statusMessage = fmt.Sprintf("Sorted by %s (%s).", m.sortField, m.sortOrderString())
@@ -369,12 +768,31 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.mapList, cmd = m.mapList.Update(msg)
 				cmds = append(cmds, cmd)
 			}
+		case stateAdopt:
+			m, cmd = m.updateAdopt(msg)
+			cmds = append(cmds, cmd)
+		case stateFilePicker:
+			m, cmd = m.updateFilePicker(msg)
+			cmds = append(cmds, cmd)
+		case stateProfiles:
+			m, cmd = m.updateProfiles(msg)
+			cmds = append(cmds, cmd)
+		case stateDetail:
+			m, cmd = m.updateDetail(msg)
+			cmds = append(cmds, cmd)
 		case stateError:
 			if msg.String() == "esc" {
 				m.state = stateExiting
 				return m, tea.Quit
 			}
 		}
+
+	default:
+		// The file picker reads folders in the background and reports back with its own messages.
+		if m.state == stateFilePicker {
+			m.filePicker, cmd = m.filePicker.Update(msg)
+			cmds = append(cmds, cmd)
+		}
 	}
 
 	return m, tea.Batch(cmds...)
@@ -389,7 +807,7 @@ func (m Model) View() string {
 	s := strings.Builder{}
 
 	var statusLine = ""
-	if m.statusMessage != "" && m.state != stateInstalling {
+	if m.statusMessage != "" {
 		statusLine = StatusMessageStyle.Render(m.statusMessage)
 	}
 
@@ -403,15 +821,50 @@ func (m Model) View() string {
 	case stateMapList:
 		sortOrder := m.sortOrderString()
 		s.WriteString(lipgloss.NewStyle().Foreground(ColorPrimary).Render(fmt.Sprintf("Found %d maps. Sorting by %s (%s).", len(m.maps), m.sortField, sortOrder)))
-		s.WriteString("\n\n")
-						s.WriteString(HelpStyle.Render("Use ↑/↓ to navigate, Enter to install, q to quit. Sort: (1) Cycle sort field, (2) Swap asc/desc."))
+		if len(m.manifest.Maps) > 0 {
+			s.WriteString(" ")
+			s.WriteString(lipgloss.NewStyle().Foreground(ColorLightGray).Render(fmt.Sprintf("%d installed using %s.", len(m.manifest.Maps), installer.FormatSize(m.diskUsage))))
+		}
+		if m.catalog != nil && m.catalog.Offline {
+			s.WriteString(" ")
+			s.WriteString(lipgloss.NewStyle().Foreground(ColorWarning).Render(fmt.Sprintf("Offline: showing cached catalog last refreshed %s.", m.catalog.AgeText())))
+		}
+		if summary := m.tagFilterSummary(); summary != "" {
+			s.WriteString(" ")
+			s.WriteString(lipgloss.NewStyle().Foreground(ColorLightGray).Render("Tags:"))
+			s.WriteString(" ")
+			s.WriteString(summary)
+		}
+		s.WriteString("\n")
+		if m.searching || m.search.Value() != "" {
+			s.WriteString(m.searchView())
+		}
 		s.WriteString("\n")
-		s.WriteString(m.mapList.View())
+						s.WriteString(HelpStyle.Render("Use ↑/↓ to navigate, / to search, t to filter by tag, i for details, Space to mark, Enter to install, u to uninstall, U to update all, b to restore previous version, e to enable/disable, p to pin/unpin, P for profiles, A to adopt manual installs, F to install from file, q to quit. Sort: (1) Cycle sort field, (2) Swap asc/desc."))
+		s.WriteString("\n")
+		if m.tagPanel != nil {
+			listView := m.mapList.View()
+			s.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, listView, m.tagPanelView(lipgloss.Height(listView))))
+		} else {
+			s.WriteString(m.mapList.View())
+		}
+		if queue := m.queueView(); queue != "" {
+			s.WriteString("\n")
+			s.WriteString(queue)
+		}
+
+	case stateAdopt:
+		s.WriteString(m.adoptView())
+
+	case stateFilePicker:
+		s.WriteString(m.filePickerView())
+
+	case stateProfiles:
+		s.WriteString(m.profilesView())
+
+	case stateDetail:
+		s.WriteString(m.detailView())
 
-	case stateInstalling:
-		s.WriteString(lipgloss.NewStyle().Foreground(ColorWarning).Render("Installing map... This might take a moment."))
-		s.WriteString("\n\n")
-		s.WriteString(m.statusMessage)
 	case stateError:
 		s.WriteString(ErrorMessageStyle.Render(fmt.Sprintf("An error occurred: %s", m.currentError.Error())))
 		s.WriteString("\n\n")
@@ -420,7 +873,7 @@ func (m Model) View() string {
 		s.WriteString(lipgloss.NewStyle().Foreground(ColorPrimary).Render("Exiting..."))
 	}
 
-	if statusLine != "" && m.state != stateInstalling {
+	if statusLine != "" {
 		s.WriteString("\n\n")
 		s.WriteString(statusLine)
 	}
@@ -431,49 +884,53 @@ func (m Model) View() string {
 // Bubble Tea Commands
 func (m Model) fetchMapsCmd() tea.Cmd {
 	return func() tea.Msg {
-		maps, err := api.FetchMaps()
+		catalog, err := m.client.FetchCatalog(context.Background())
 		if err != nil {
 			return errMsg{err}
 		}
-		return mapsFetchedMsg(maps)
+		return mapsFetchedMsg{catalog: catalog}
 	}
 }
 
-func (m Model) installMapCmd(mapToInstall api.Map, installDir string) tea.Cmd {
-	// Start the installation in a goroutine
-	go func() {
-		// installer.InstallMap will send messages to m.progressChan
-		err := installer.InstallMap(mapToInstall, installDir, m.progressChan)
+func (m Model) uninstallMapCmd(mapToRemove api.Map) tea.Cmd {
+	return func() tea.Msg {
+		_, err := installer.UninstallMap(strconv.Itoa(mapToRemove.ID))
 		if err != nil {
-			Logger.Printf("Installer: Failed to install '%s': %v", mapToInstall.Name, err)
-		} else {
-			Logger.Printf("Installer: Successfully installed '%s'", mapToInstall.Name)
+			Logger.Printf("Installer: Failed to uninstall '%s': %v", mapToRemove.Name, err)
 		}
-		// Send a final message to indicate completion or error
-		m.doneChan <- installDoneMsg{mapName: mapToInstall.Name, err: err}
-	}()
+		return uninstallDoneMsg{mapName: mapToRemove.Name, err: err}
+	}
+}
 
-	// Return a command that continuously reads from the progress channel
-	return tea.Batch(m.progressTickCmd(), m.installDoneCmd())
+func (m Model) restoreMapCmd(mapToRestore api.Map) tea.Cmd {
+	return func() tea.Msg {
+		rec, err := installer.RestoreBackup(strconv.Itoa(mapToRestore.ID), "")
+		if err != nil {
+			Logger.Printf("Installer: Failed to restore '%s': %v", mapToRestore.Name, err)
+		}
+		return restoreDoneMsg{mapName: mapToRestore.Name, rec: rec, err: err}
+	}
 }
 
-func (m Model) progressTickCmd() tea.Cmd {
-	return tea.Tick(time.Millisecond*100, func(t time.Time) tea.Msg {
-		select {
-		case msg, ok := <-m.progressChan:
-			if !ok {
-				return nil // Channel closed, no more messages
-			}
-			return installProgressMsg(msg)
-		default:
-			return progressTickMsg{}
+// toggleMapCmd disables an enabled map or enables a disabled one.
+func (m Model) toggleMapCmd(mapToToggle api.Map, enable bool) tea.Cmd {
+	return func() tea.Msg {
+		rec, err := installer.SetEnabled(strconv.Itoa(mapToToggle.ID), enable)
+		if err != nil {
+			Logger.Printf("Installer: Failed to toggle '%s': %v", mapToToggle.Name, err)
 		}
-	})
+		return toggleDoneMsg{mapName: mapToToggle.Name, rec: rec, err: err}
+	}
 }
 
-func (m Model) installDoneCmd() tea.Cmd {
+// pinMapCmd pins a map to its installed version or unpins it.
+func (m Model) pinMapCmd(mapToPin api.Map, pin bool) tea.Cmd {
 	return func() tea.Msg {
-		return <-m.doneChan
+		rec, err := installer.SetPinned(strconv.Itoa(mapToPin.ID), pin)
+		if err != nil {
+			Logger.Printf("Installer: Failed to pin or unpin '%s': %v", mapToPin.Name, err)
+		}
+		return pinDoneMsg{mapName: mapToPin.Name, rec: rec, err: err}
 	}
 }
 
@@ -0,0 +1,241 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/api"
+	"github.com/ShawnEdgell/skaterxl-map-manager/installer"
+)
+
+type profilesLoadedMsg struct {
+	profiles []*installer.Profile
+	status   string // Outcome of the change that triggered the reload, if any
+	err      error
+}
+type profileAppliedMsg struct {
+	profile *installer.Profile
+	plan    *installer.ProfilePlan
+	err     error
+}
+
+// profileScreen lists the saved profiles. While naming is set, the name input for a new profile has focus.
+type profileScreen struct {
+	profiles []*installer.Profile
+	cursor   int
+	naming   bool
+	name     textinput.Model
+}
+
+// loadProfilesCmd reads the saved profiles, reporting status once they are loaded.
+func loadProfilesCmd(status string) tea.Cmd {
+	return func() tea.Msg {
+		profiles, err := installer.LoadProfiles()
+		return profilesLoadedMsg{profiles: profiles, status: status, err: err}
+	}
+}
+
+func createProfileCmd(name string, replace bool) tea.Cmd {
+	return func() tea.Msg {
+		profile, err := installer.CreateProfile(name, replace)
+		if err != nil {
+			Logger.Printf("Installer: Failed to save profile '%s': %v", name, err)
+			return profilesLoadedMsg{err: err}
+		}
+		return loadProfilesCmd(fmt.Sprintf("Saved profile %s with the %d maps enabled now.", profile.Name, len(profile.Maps)))()
+	}
+}
+
+func deleteProfileCmd(name string) tea.Cmd {
+	return func() tea.Msg {
+		profile, err := installer.DeleteProfile(name)
+		if err != nil {
+			Logger.Printf("Installer: Failed to delete profile '%s': %v", name, err)
+			return profilesLoadedMsg{err: err}
+		}
+		return loadProfilesCmd(fmt.Sprintf("Deleted profile %s. Installed maps were not changed.", profile.Name))()
+	}
+}
+
+func applyProfileCmd(profile *installer.Profile) tea.Cmd {
+	return func() tea.Msg {
+		plan, err := installer.ApplyProfile(profile)
+		if err != nil {
+			Logger.Printf("Installer: Failed to apply profile '%s': %v", profile.Name, err)
+		}
+		return profileAppliedMsg{profile: profile, plan: plan, err: err}
+	}
+}
+
+// openProfiles switches to the profile screen and loads the saved profiles.
+func (m *Model) openProfiles() tea.Cmd {
+	name := textinput.New()
+	name.Placeholder = "Filming"
+	name.CharLimit = 64
+	m.profiles = &profileScreen{name: name}
+	m.state = stateProfiles
+	m.statusMessage = ""
+	return loadProfilesCmd("")
+}
+
+// showProfiles updates the profile screen after the profiles were loaded or changed.
+func (m *Model) showProfiles(msg profilesLoadedMsg) {
+	if m.profiles == nil {
+		return
+	}
+	if msg.err != nil {
+		m.statusMessage = ErrorMessageStyle.Render(msg.err.Error())
+		return
+	}
+	m.profiles.profiles = msg.profiles
+	m.profiles.cursor = min(m.profiles.cursor, max(len(msg.profiles)-1, 0))
+	m.statusMessage = msg.status
+}
+
+// finishApply refreshes the map list after a profile was applied and queues the profile's missing maps.
+func (m *Model) finishApply(msg profileAppliedMsg) tea.Cmd {
+	m.reloadInstalled()
+	if msg.plan == nil {
+		m.statusMessage = ErrorMessageStyle.Render(fmt.Sprintf("Could not apply profile %s: %v", msg.profile.Name, msg.err))
+		return nil
+	}
+
+	var missing []api.Map
+	var unavailable []string
+	for _, pm := range msg.plan.Missing {
+		if mapData, ok := m.catalogMap(pm.MapID); ok {
+			missing = append(missing, mapData)
+		} else {
+			unavailable = append(unavailable, pm.Name)
+		}
+	}
+
+	status := fmt.Sprintf("Applied profile %s: enabled %d, disabled %d, queued %d to install.", msg.profile.Name, len(msg.plan.Enable), len(msg.plan.Disable), len(missing))
+	switch {
+	case msg.err != nil:
+		m.statusMessage = ErrorMessageStyle.Render(fmt.Sprintf("%s Some maps could not be switched: %v", status, msg.err))
+	case len(unavailable) > 0:
+		m.statusMessage = ErrorMessageStyle.Render(fmt.Sprintf("%s Not in the catalog: %s.", status, strings.Join(unavailable, ", ")))
+	case msg.plan.Empty():
+		m.statusMessage = StatusMessageStyle.Render(fmt.Sprintf("Profile %s is already active.", msg.profile.Name))
+	default:
+		m.statusMessage = StatusMessageStyle.Render(status)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return m.enqueue(missing...)
+}
+
+// catalogMap returns the catalog entry with the given ID.
+func (m *Model) catalogMap(id int) (api.Map, bool) {
+	for _, mapData := range m.maps {
+		if mapData.ID == id {
+			return mapData, true
+		}
+	}
+	return api.Map{}, false
+}
+
+// updateProfiles handles keys on the profile screen.
+func (m Model) updateProfiles(msg tea.KeyMsg) (Model, tea.Cmd) {
+	screen := m.profiles
+	if screen.naming {
+		switch msg.Type {
+		case tea.KeyEsc:
+			screen.naming = false
+			screen.name.Blur()
+			return m, nil
+		case tea.KeyEnter:
+			name := strings.TrimSpace(screen.name.Value())
+			if name == "" {
+				m.statusMessage = ErrorMessageStyle.Render("Profile name cannot be empty.")
+				return m, nil
+			}
+			screen.naming = false
+			screen.name.Blur()
+			return m, createProfileCmd(name, false)
+		}
+		var cmd tea.Cmd
+		screen.name, cmd = screen.name.Update(msg)
+		return m, cmd
+	}
+
+	var selected *installer.Profile
+	if screen.cursor < len(screen.profiles) {
+		selected = screen.profiles[screen.cursor]
+	}
+	switch msg.String() {
+	case "up", "k":
+		if screen.cursor > 0 {
+			screen.cursor--
+		}
+	case "down", "j":
+		if screen.cursor < len(screen.profiles)-1 {
+			screen.cursor++
+		}
+	case "n":
+		screen.naming = true
+		screen.name.SetValue("")
+		m.statusMessage = ""
+		return m, screen.name.Focus()
+	case "s":
+		if selected != nil {
+			return m, createProfileCmd(selected.Name, true)
+		}
+	case "d":
+		if selected != nil {
+			return m, deleteProfileCmd(selected.Name)
+		}
+	case "enter":
+		if selected != nil {
+			m.profiles = nil
+			m.state = stateMapList
+			m.statusMessage = StatusMessageStyle.Render(fmt.Sprintf("Applying profile %s...", selected.Name))
+			return m, applyProfileCmd(selected)
+		}
+	case "esc":
+		m.profiles = nil
+		m.state = stateMapList
+		m.statusMessage = ""
+	}
+	return m, nil
+}
+
+// profilesView renders the saved profiles, marking the one matching the enabled maps.
+func (m Model) profilesView() string {
+	screen := m.profiles
+	s := strings.Builder{}
+	s.WriteString(QueueHeaderStyle.Render("Profiles: named sets of enabled maps. Applying one enables, disables and installs maps to match."))
+	s.WriteString("\n")
+	s.WriteString(HelpStyle.Render("Use ↑/↓ to navigate, Enter to apply, n to save the enabled maps as a new profile, s to overwrite the selected profile with them, d to delete, Esc to go back."))
+	s.WriteString("\n\n")
+
+	if len(screen.profiles) == 0 {
+		s.WriteString(ListItemStyle.Render("No profiles yet. Press n to save the maps enabled now."))
+		s.WriteString("\n")
+	}
+	for i, profile := range screen.profiles {
+		line := fmt.Sprintf("%s %s", profile.Name, SizeTagStyle.Render(fmt.Sprintf("(%d maps)", len(profile.Maps))))
+		if m.manifest != nil && profile.Active(m.manifest) {
+			line += " " + InstalledTagStyle.Render("[active]")
+		}
+		if i == screen.cursor {
+			s.WriteString(SelectedItemStyle.Render(">" + line))
+		} else {
+			s.WriteString(ListItemStyle.Render(" " + line))
+		}
+		s.WriteString("\n")
+	}
+
+	if screen.naming {
+		s.WriteString("\n")
+		s.WriteString(PromptStyle.Render("Name for the new profile (Enter to save, Esc to cancel):"))
+		s.WriteString("\n")
+		s.WriteString(screen.name.View())
+	}
+	return s.String()
+}
@@ -0,0 +1,228 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/api"
+	"github.com/ShawnEdgell/skaterxl-map-manager/installer"
+)
+
+// maxQueueLines is the number of queue entries shown in the queue panel.
+const maxQueueLines = 5
+
+type queueEventMsg installer.QueueEvent
+type queueClosedMsg struct{}
+
+// queueEntry is the UI's view of one map in the install queue.
+type queueEntry struct {
+	mapData   api.Map
+	status    installer.QueueStatus
+	progress  installer.ProgressMsg
+	err       error
+	localPath string // Archive or folder of a local install
+}
+
+// enqueue adds maps to the install queue, starting the queue on first use.
+func (m *Model) enqueue(maps ...api.Map) tea.Cmd {
+	var cmd tea.Cmd
+	if m.queue == nil {
+		m.queue = installer.NewQueue(context.Background(), m.client, m.skaterXLMapsDir, m.config.DownloadConcurrency)
+		cmd = listenQueueCmd(m.queue)
+	}
+
+	added := 0
+	for _, mapData := range maps {
+		if !m.queue.Add(mapData) {
+			continue
+		}
+		added++
+		m.setQueueEntry(installer.QueueEvent{Map: mapData, Status: installer.StatusPending})
+	}
+	Logger.Printf("enqueue: queued %d of %d maps.", added, len(maps))
+	m.resizeList()
+	return cmd
+}
+
+// enqueueLocal adds a local archive or folder to the install queue, starting the queue on first use.
+func (m *Model) enqueueLocal(mapData api.Map, path string) tea.Cmd {
+	var cmd tea.Cmd
+	if m.queue == nil {
+		m.queue = installer.NewQueue(context.Background(), m.client, m.skaterXLMapsDir, m.config.DownloadConcurrency)
+		cmd = listenQueueCmd(m.queue)
+	}
+	if m.queue.AddLocal(mapData, path) {
+		m.setQueueEntry(installer.QueueEvent{Map: mapData, Status: installer.StatusPending})
+		m.queueEntries[len(m.queueEntries)-1].localPath = path
+	}
+	m.resizeList()
+	return cmd
+}
+
+// setQueueEntry updates the entry for event.Map, appending a new one if the map is not in the panel yet.
+func (m *Model) setQueueEntry(event installer.QueueEvent) {
+	for _, entry := range m.queueEntries {
+		if entry.mapData.ID == event.Map.ID && entry.status != installer.StatusDone && entry.status != installer.StatusFailed {
+			entry.status = event.Status
+			entry.progress = event.Progress
+			entry.err = event.Err
+			return
+		}
+	}
+	m.queueEntries = append(m.queueEntries, &queueEntry{
+		mapData:  event.Map,
+		status:   event.Status,
+		progress: event.Progress,
+		err:      event.Err,
+	})
+}
+
+// clearFinished removes done and failed entries from the queue panel.
+func (m *Model) clearFinished() {
+	var remaining []*queueEntry
+	for _, entry := range m.queueEntries {
+		if entry.status != installer.StatusDone && entry.status != installer.StatusFailed {
+			remaining = append(remaining, entry)
+		}
+	}
+	m.queueEntries = remaining
+	m.resizeList()
+}
+
+// retryFailed re-queues every failed entry and returns how many were retried.
+func (m *Model) retryFailed() (tea.Cmd, int) {
+	var failed []*queueEntry
+	for _, entry := range m.queueEntries {
+		if entry.status == installer.StatusFailed {
+			failed = append(failed, entry)
+		}
+	}
+	if len(failed) == 0 {
+		return nil, 0
+	}
+	m.clearFailed()
+
+	var cmds []tea.Cmd
+	for _, entry := range failed {
+		if entry.localPath != "" {
+			cmds = append(cmds, m.enqueueLocal(entry.mapData, entry.localPath))
+		} else {
+			cmds = append(cmds, m.enqueue(entry.mapData))
+		}
+	}
+	return tea.Batch(cmds...), len(failed)
+}
+
+// clearFailed removes failed entries from the queue panel.
+func (m *Model) clearFailed() {
+	var remaining []*queueEntry
+	for _, entry := range m.queueEntries {
+		if entry.status != installer.StatusFailed {
+			remaining = append(remaining, entry)
+		}
+	}
+	m.queueEntries = remaining
+}
+
+// describeInstallError explains install failures the user can act on.
+func describeInstallError(err error) string {
+	var integrityErr *installer.IntegrityError
+	if errors.As(err, &integrityErr) {
+		return fmt.Sprintf("the download was incomplete or corrupted (%s mismatch: expected %s, got %s). The bad file was removed; press r to download it again",
+			integrityErr.Check, integrityErr.Expected, integrityErr.Actual)
+	}
+	var spaceErr *installer.DiskSpaceError
+	if errors.As(err, &spaceErr) {
+		return fmt.Sprintf("not enough disk space in %s (%s needed, %s free). Free up space and press r to retry",
+			spaceErr.Path, installer.FormatSize(spaceErr.Required), installer.FormatSize(spaceErr.Available))
+	}
+	var limitErr *installer.LimitError
+	if errors.As(err, &limitErr) {
+		return fmt.Sprintf("%v. The archive may be a decompression bomb; if you trust it, raise the extract_max_* limits with smm config set", limitErr)
+	}
+	return err.Error()
+}
+
+// queueCounts returns the number of entries in each status.
+func (m *Model) queueCounts() map[installer.QueueStatus]int {
+	counts := map[installer.QueueStatus]int{}
+	for _, entry := range m.queueEntries {
+		counts[entry.status]++
+	}
+	return counts
+}
+
+// queuePanelHeight is the number of lines queueView renders.
+func (m *Model) queuePanelHeight() int {
+	if len(m.queueEntries) == 0 {
+		return 0
+	}
+	return min(len(m.queueEntries), maxQueueLines) + 2
+}
+
+// queueView renders the queue panel: a summary line followed by the most relevant entries.
+func (m Model) queueView() string {
+	if len(m.queueEntries) == 0 {
+		return ""
+	}
+
+	counts := m.queueCounts()
+	s := strings.Builder{}
+	help := "c to clear finished"
+	if counts[installer.StatusFailed] > 0 {
+		help = "r to retry failed, c to clear finished"
+	}
+	s.WriteString(QueueHeaderStyle.Render(fmt.Sprintf("Queue: %d pending, %d downloading, %d extracting, %d done, %d failed (%s)",
+		counts[installer.StatusPending], counts[installer.StatusDownloading]+counts[installer.StatusVerifying], counts[installer.StatusExtracting],
+		counts[installer.StatusDone], counts[installer.StatusFailed], help)))
+
+	// Show active entries first, then pending, then finished ones.
+	var ordered []*queueEntry
+	for _, want := range []installer.QueueStatus{installer.StatusDownloading, installer.StatusVerifying, installer.StatusExtracting, installer.StatusPending, installer.StatusFailed, installer.StatusDone} {
+		for _, entry := range m.queueEntries {
+			if entry.status == want {
+				ordered = append(ordered, entry)
+			}
+		}
+	}
+	for i, entry := range ordered {
+		if i == maxQueueLines {
+			break
+		}
+		s.WriteString("\n")
+		s.WriteString(renderQueueEntry(entry))
+	}
+	return s.String()
+}
+
+func renderQueueEntry(entry *queueEntry) string {
+	line := fmt.Sprintf("%-11s %s", entry.status, entry.mapData.Name)
+	switch entry.status {
+	case installer.StatusDownloading, installer.StatusVerifying, installer.StatusExtracting:
+		if entry.progress.Total > 0 {
+			line += fmt.Sprintf(" %.0f%%", float64(entry.progress.Current)/float64(entry.progress.Total)*100)
+		}
+		return QueueActiveStyle.Render(line)
+	case installer.StatusFailed:
+		return ErrorMessageStyle.Render(fmt.Sprintf("%s: %s", line, describeInstallError(entry.err)))
+	case installer.StatusDone:
+		return StatusMessageStyle.Render(line)
+	default:
+		return QueuePendingStyle.Render(line)
+	}
+}
+
+// listenQueueCmd waits for the next queue event.
+func listenQueueCmd(q *installer.Queue) tea.Cmd {
+	return func() tea.Msg {
+		event, ok := <-q.Events()
+		if !ok {
+			return queueClosedMsg{}
+		}
+		return queueEventMsg(event)
+	}
+}
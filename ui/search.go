@@ -0,0 +1,131 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/api"
+)
+
+const searchHelp = "Fuzzy search by name, author, summary or tag. Filters: author:name tag:name downloads>1000 subscribers<50."
+
+// newSearchInput returns the input for the map list search.
+func newSearchInput() textinput.Model {
+	input := textinput.New()
+	input.Prompt = "/ "
+	input.Placeholder = "name, author:name, tag:park, downloads>1000"
+	input.CharLimit = 200
+	input.PromptStyle = PromptStyle
+	input.TextStyle = lipgloss.NewStyle().Foreground(ColorText)
+	return input
+}
+
+// startSearch focuses the search input, keeping the current query so it can be refined.
+func (m *Model) startSearch() tea.Cmd {
+	m.searching = true
+	m.statusMessage = searchHelp
+	return m.search.Focus()
+}
+
+// clearSearch drops the query and shows every map again.
+func (m *Model) clearSearch() {
+	m.searching = false
+	m.search.Blur()
+	m.search.SetValue("")
+	m.query = nil
+	m.statusMessage = ""
+	m.filterItems()
+	m.mapList.Select(0)
+}
+
+// updateSearch handles keys while the search input has focus. The results update as the query is typed;
+// Enter keeps them and returns the keys to the list, Esc clears the search.
+func (m Model) updateSearch(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.clearSearch()
+		return m, nil
+	case tea.KeyEnter:
+		m.searching = false
+		m.search.Blur()
+		m.statusMessage = ""
+		return m, nil
+	case tea.KeyUp, tea.KeyDown:
+		var cmd tea.Cmd
+		m.mapList, cmd = m.mapList.Update(msg)
+		return m, cmd
+	}
+
+	var cmd tea.Cmd
+	previous := m.search.Value()
+	m.search, cmd = m.search.Update(msg)
+	if m.search.Value() != previous {
+		m.applySearch()
+	}
+	return m, cmd
+}
+
+// applySearch parses the typed query and filters the list. A query that does not parse leaves the previous
+// results in place and explains the problem.
+func (m *Model) applySearch() {
+	query, err := api.ParseQuery(m.search.Value())
+	if err != nil {
+		m.statusMessage = ErrorMessageStyle.Render(err.Error())
+		return
+	}
+	m.statusMessage = searchHelp
+	m.query = query
+	if query.Empty() {
+		m.query = nil
+	}
+	m.filterItems()
+	m.mapList.Select(0)
+}
+
+// searchView renders the search input and the number of matching maps.
+func (m Model) searchView() string {
+	count := SizeTagStyle.Render(fmt.Sprintf("%d of %d maps match.", len(m.mapList.Items()), len(m.maps)+len(m.localMaps)))
+	if m.searching {
+		return m.search.View() + "  " + count
+	}
+	return PromptStyle.Render("/ ") + m.search.Value() + "  " + count + " " + HelpStyle.Render("(/ to edit, Esc to clear)")
+}
+
+// highlight renders the runes of s at the given positions with MatchHighlightStyle.
+func highlight(s string, positions []int) string {
+	if len(positions) == 0 {
+		return s
+	}
+	matched := make(map[int]bool, len(positions))
+	for _, p := range positions {
+		matched[p] = true
+	}
+	var b strings.Builder
+	for i, r := range []rune(s) {
+		if matched[i] {
+			b.WriteString(MatchHighlightStyle.Render(string(r)))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// matchHints renders the author and tags a search matched, which the list does not show otherwise.
+func matchHints(mapData api.Map, match *api.SearchMatch) string {
+	if match == nil {
+		return ""
+	}
+	var hints []string
+	if len(match.Author) > 0 {
+		hints = append(hints, SizeTagStyle.Render("by ")+highlight(mapData.SubmittedBy.Username, match.Author))
+	}
+	for _, tag := range match.Tags {
+		hints = append(hints, SizeTagStyle.Render("#")+MatchHighlightStyle.Render(tag))
+	}
+	return strings.Join(hints, " ")
+}
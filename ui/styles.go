@@ -50,6 +50,11 @@ var (
 		Foreground(ColorError).
 		Padding(0, 1)
 
+	// Warning Message Styles (questions before destructive actions)
+	WarningMessageStyle = lipgloss.NewStyle().
+		Foreground(ColorWarning).
+		Padding(0, 1)
+
 	// --- Input Field Styles ---
 	// Style for prompts (e.g., "Enter your directory:")
 	PromptStyle = lipgloss.NewStyle().
@@ -90,4 +95,71 @@ var (
 		// Background(ColorDarkGray). // Removed: For transparent background, highlight will be just foreground
 		Bold(true)
 
+	// Marker shown next to maps that are installed and up to date
+	InstalledTagStyle = lipgloss.NewStyle().
+		Foreground(ColorSuccess)
+
+	// Marker shown next to installed maps with a newer release in the catalog
+	UpdateTagStyle = lipgloss.NewStyle().
+		Foreground(ColorWarning).
+		Bold(true)
+
+	// Marker shown next to installed maps moved out of the maps directory
+	DisabledTagStyle = lipgloss.NewStyle().
+		Foreground(ColorLightGray).
+		Italic(true)
+
+	// Marker shown next to installed maps pinned to their version, which updates skip
+	PinnedTagStyle = lipgloss.NewStyle().
+		Foreground(ColorPrimary)
+
+	// Letters of a map name, author or tag matched by the search
+	MatchHighlightStyle = lipgloss.NewStyle().
+		Foreground(ColorAccent).
+		Bold(true).
+		Underline(true)
+
+	// Tags in the tag panel, by filter state: not filtered, required, or hidden
+	TagStyle = lipgloss.NewStyle().
+		Foreground(ColorText)
+	TagIncludedStyle = lipgloss.NewStyle().
+		Foreground(ColorSuccess).
+		Bold(true)
+	TagExcludedStyle = lipgloss.NewStyle().
+		Foreground(ColorError).
+		Strikethrough(true)
+
+	// Install button of the map detail pane
+	ButtonStyle = lipgloss.NewStyle().
+		Foreground(ColorText).
+		Background(ColorAccent).
+		Bold(true).
+		Padding(0, 2)
+
+	// Size shown after each map: disk usage when installed, download size otherwise
+	SizeTagStyle = lipgloss.NewStyle().
+		Foreground(ColorDarkGray)
+
+	// Marker shown in front of maps marked for a batch install
+	MarkedItemStyle = lipgloss.NewStyle().
+		Foreground(ColorPrimary).
+		Bold(true)
+
+	// --- Queue Panel Styles ---
+	// Summary line at the top of the install queue panel
+	QueueHeaderStyle = lipgloss.NewStyle().
+		Foreground(ColorPrimary).
+		Bold(true).
+		Padding(0, 1)
+
+	// Queue entries that are downloading or extracting
+	QueueActiveStyle = lipgloss.NewStyle().
+		Foreground(ColorWarning).
+		Padding(0, 1)
+
+	// Queue entries waiting for a worker
+	QueuePendingStyle = lipgloss.NewStyle().
+		Foreground(ColorLightGray).
+		Padding(0, 1)
+
 	)
\ No newline at end of file
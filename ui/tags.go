@@ -0,0 +1,216 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/ShawnEdgell/skaterxl-map-manager/api"
+	"github.com/ShawnEdgell/skaterxl-map-manager/config"
+)
+
+// tagPanelWidth is the width of the tag panel beside the map list, borders included.
+const tagPanelWidth = 32
+
+// Tag filter states, cycled by Space in the tag panel.
+const (
+	tagOff = iota
+	tagIncluded
+	tagExcluded
+)
+
+// tagPanel lists the catalog tags with the number of maps carrying each.
+type tagPanel struct {
+	tags   []api.TagCount
+	cursor int
+}
+
+// tagFilter returns the tag filter of the map list, which is kept in the configuration between sessions.
+func (m *Model) tagFilter() api.TagFilter {
+	if m.config == nil {
+		return api.TagFilter{}
+	}
+	return api.TagFilter{Include: m.config.TagInclude, Exclude: m.config.TagExclude}
+}
+
+// openTagPanel shows the tag panel beside the map list and gives it the keys. Filtered tags the catalog no
+// longer uses are listed too, so they can be cleared.
+func (m *Model) openTagPanel() {
+	tags := api.CountTags(m.maps)
+	filter := m.tagFilter()
+	for _, name := range append(append([]string{}, filter.Include...), filter.Exclude...) {
+		if tagIndex(tags, name) < 0 {
+			tags = append(tags, api.TagCount{Name: name})
+		}
+	}
+	m.tagPanel = &tagPanel{tags: tags}
+	m.statusMessage = ""
+	m.resizeList()
+}
+
+func (m *Model) closeTagPanel() {
+	m.tagPanel = nil
+	m.resizeList()
+}
+
+func tagIndex(tags []api.TagCount, name string) int {
+	for i, tag := range tags {
+		if strings.EqualFold(tag.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// tagState reports whether the named tag is included in, excluded from or not part of the filter.
+func (m *Model) tagState(name string) int {
+	filter := m.tagFilter()
+	for _, tag := range filter.Include {
+		if strings.EqualFold(tag, name) {
+			return tagIncluded
+		}
+	}
+	for _, tag := range filter.Exclude {
+		if strings.EqualFold(tag, name) {
+			return tagExcluded
+		}
+	}
+	return tagOff
+}
+
+// setTagState moves the named tag into the include or exclude list of the filter, or out of both, saves the
+// filter and refilters the list.
+func (m *Model) setTagState(name string, state int) {
+	without := func(tags []string) []string {
+		var kept []string
+		for _, tag := range tags {
+			if !strings.EqualFold(tag, name) {
+				kept = append(kept, tag)
+			}
+		}
+		return kept
+	}
+	m.config.TagInclude = without(m.config.TagInclude)
+	m.config.TagExclude = without(m.config.TagExclude)
+	switch state {
+	case tagIncluded:
+		m.config.TagInclude = append(m.config.TagInclude, name)
+	case tagExcluded:
+		m.config.TagExclude = append(m.config.TagExclude, name)
+	}
+	m.saveTagFilter()
+}
+
+// saveTagFilter stores the tag filter in the configuration and refilters the list.
+func (m *Model) saveTagFilter() {
+	Logger.Printf("Update: Tag filter is now include %v, exclude %v.", m.config.TagInclude, m.config.TagExclude)
+	if err := config.SaveConfig(m.config); err != nil {
+		Logger.Printf("Update: Error saving tag filter: %v", err)
+		m.statusMessage = ErrorMessageStyle.Render(fmt.Sprintf("Could not save the tag filter: %v", err))
+	} else {
+		m.statusMessage = ""
+	}
+	m.filterItems()
+	m.mapList.Select(0)
+}
+
+// updateTagPanel handles keys while the tag panel is open.
+func (m Model) updateTagPanel(msg tea.KeyMsg) (Model, tea.Cmd) {
+	panel := m.tagPanel
+	var selected string
+	if panel.cursor < len(panel.tags) {
+		selected = panel.tags[panel.cursor].Name
+	}
+	switch msg.String() {
+	case "up", "k":
+		if panel.cursor > 0 {
+			panel.cursor--
+		}
+	case "down", "j":
+		if panel.cursor < len(panel.tags)-1 {
+			panel.cursor++
+		}
+	case " ", "enter":
+		if selected != "" {
+			m.setTagState(selected, (m.tagState(selected)+1)%3)
+		}
+	case "+", "i":
+		if selected != "" {
+			m.setTagState(selected, tagIncluded)
+		}
+	case "-", "x":
+		if selected != "" {
+			m.setTagState(selected, tagExcluded)
+		}
+	case "c":
+		m.config.TagInclude, m.config.TagExclude = nil, nil
+		m.saveTagFilter()
+	case "esc", "t":
+		m.closeTagPanel()
+	}
+	return m, nil
+}
+
+// tagPanelView renders the tag panel at the given height, scrolled to keep the cursor in view.
+func (m Model) tagPanelView(height int) string {
+	panel := m.tagPanel
+	inner := tagPanelWidth - 2
+	rows := max(height-4, 1) // Border, title and help line
+	start := min(max(panel.cursor-rows/2, 0), max(len(panel.tags)-rows, 0))
+
+	s := strings.Builder{}
+	s.WriteString(PromptStyle.Render("Tags"))
+	s.WriteString("\n")
+	if len(panel.tags) == 0 {
+		s.WriteString(HelpStyle.Render("No tags in the catalog."))
+		s.WriteString("\n")
+	}
+	for i := start; i < len(panel.tags) && i < start+rows; i++ {
+		tag := panel.tags[i]
+		mark, style := "  ", TagStyle
+		switch m.tagState(tag.Name) {
+		case tagIncluded:
+			mark, style = "+ ", TagIncludedStyle
+		case tagExcluded:
+			mark, style = "- ", TagExcludedStyle
+		}
+		count := fmt.Sprint(tag.Count)
+		name := truncate(tag.Name, inner-len(mark)-len(count)-3)
+		line := mark + name + strings.Repeat(" ", max(inner-len(mark)-lipgloss.Width(name)-len(count)-2, 1)) + count
+		if i == panel.cursor {
+			s.WriteString(SelectedItemStyle.Render(">" + line))
+		} else {
+			s.WriteString(style.Render(" " + line))
+		}
+		s.WriteString("\n")
+	}
+	s.WriteString(HelpStyle.Render("Space toggle, c clear, Esc"))
+	return BorderStyle.Width(inner).Height(height - 2).Render(s.String())
+}
+
+// tagFilterSummary describes the active tag filter for the header, such as "+Park -Street".
+func (m Model) tagFilterSummary() string {
+	filter := m.tagFilter()
+	var parts []string
+	for _, tag := range filter.Include {
+		parts = append(parts, TagIncludedStyle.Render("+"+tag))
+	}
+	for _, tag := range filter.Exclude {
+		parts = append(parts, TagExcludedStyle.Render("-"+tag))
+	}
+	return strings.Join(parts, " ")
+}
+
+// truncate shortens s to at most width columns, ending it with an ellipsis when cut.
+func truncate(s string, width int) string {
+	if lipgloss.Width(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && lipgloss.Width(string(runes))+1 > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}